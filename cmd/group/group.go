@@ -0,0 +1,12 @@
+package group
+
+import "github.com/spf13/cobra"
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Group related queries",
+}
+
+func Add(root *cobra.Command) {
+	root.AddCommand(groupCmd)
+}
@@ -0,0 +1,88 @@
+package group
+
+import (
+	"errors"
+	"log"
+
+	"github.com/mvannes/golab/cmd/internal/civariable"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+var flagRevealVariables bool
+var flagVariableEnvironmentScope string
+
+func resolveGroup(c *gitlab.GitlabClient, path string) *lab.Group {
+	group, err := c.Group(path)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	if nil == group {
+		log.Fatal(errors.New("No group found"))
+	}
+	return group
+}
+
+var variablesCmd = &cobra.Command{
+	Use:   "ci-variables",
+	Short: "Get the CI variables of a [group]",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		group := resolveGroup(c, args[0])
+
+		variables, err := c.GroupCIVariables(*group)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		civariable.Print(civariable.FromGroupVariables(variables), flagRevealVariables)
+	},
+}
+
+var variableCmd = &cobra.Command{
+	Use:   "ci-variable",
+	Short: "Create, update or delete group CI variables",
+}
+
+var setVariableCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Create or update a CI variable of a group [group] [key] [value]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		group := resolveGroup(c, args[0])
+
+		err := c.SetGroupCIVariable(*group, civariable.SettingsFromFlags(cmd, args[1], args[2]))
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var deleteVariableCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a CI variable of a group [group] [key]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		group := resolveGroup(c, args[0])
+
+		err := c.RemoveGroupCIVariable(*group, args[1], flagVariableEnvironmentScope)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+func init() {
+	variablesCmd.Flags().BoolVar(&flagRevealVariables, "reveal", false, "print variable values instead of redacting them")
+	civariable.AddSettingsFlags(setVariableCmd)
+	deleteVariableCmd.Flags().StringVarP(&flagVariableEnvironmentScope, "environment-scope", "e", "*", "environment scope of the variable")
+
+	variableCmd.AddCommand(setVariableCmd)
+	variableCmd.AddCommand(deleteVariableCmd)
+	groupCmd.AddCommand(variablesCmd)
+	groupCmd.AddCommand(variableCmd)
+}
@@ -0,0 +1,79 @@
+// Package civariable holds what the project and group commands share for showing and setting CI variables.
+package civariable
+
+import (
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// Redacted is shown instead of the value of a variable, unless the values are revealed.
+const Redacted = "[redacted]"
+
+// Value returns the value of the variable to show, which is redacted unless reveal is set.
+func Value(v *lab.ProjectVariable, reveal bool) string {
+	if reveal {
+		return v.Value
+	}
+	return Redacted
+}
+
+// Print prints the variables as a table, redacting their values unless reveal is set.
+func Print(variables []*lab.ProjectVariable, reveal bool) {
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Key", "Value", "Type", "Environment scope", "Protected", "Masked")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, v := range variables {
+		tbl.AddRow(v.Key, Value(v, reveal), v.VariableType, v.EnvironmentScope, v.Protected, v.Masked)
+	}
+	tbl.Print()
+}
+
+// FromGroupVariables converts group variables, so they can be shown like project variables.
+func FromGroupVariables(variables []*lab.GroupVariable) []*lab.ProjectVariable {
+	var result []*lab.ProjectVariable
+	for _, v := range variables {
+		result = append(result, &lab.ProjectVariable{
+			Key:              v.Key,
+			Value:            v.Value,
+			VariableType:     v.VariableType,
+			Protected:        v.Protected,
+			Masked:           v.Masked,
+			EnvironmentScope: v.EnvironmentScope,
+		})
+	}
+	return result
+}
+
+// AddSettingsFlags adds the flags for the type, environment scope and attributes of a variable.
+func AddSettingsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("type", "t", "", "variable type. [env_var|file]")
+	cmd.Flags().StringP("environment-scope", "e", "*", "environment scope of the variable")
+	cmd.Flags().BoolP("protected", "p", false, "only expose the variable to protected branches and tags")
+	cmd.Flags().BoolP("masked", "m", false, "mask the variable in job logs")
+	cmd.Flags().BoolP("raw", "r", false, "do not expand variable references in the value")
+}
+
+// SettingsFromFlags returns the settings of a variable given through the flags of AddSettingsFlags. Attributes whose flags
+// were not given are left unset.
+func SettingsFromFlags(cmd *cobra.Command, key, value string) gitlab.CIVariableSettings {
+	settings := gitlab.CIVariableSettings{Key: key, Value: value}
+	settings.VariableType, _ = cmd.Flags().GetString("type")
+	settings.EnvironmentScope, _ = cmd.Flags().GetString("environment-scope")
+	if cmd.Flag("protected").Changed {
+		protected, _ := cmd.Flags().GetBool("protected")
+		settings.Protected = &protected
+	}
+	if cmd.Flag("masked").Changed {
+		masked, _ := cmd.Flags().GetBool("masked")
+		settings.Masked = &masked
+	}
+	if cmd.Flag("raw").Changed {
+		raw, _ := cmd.Flags().GetBool("raw")
+		settings.Raw = &raw
+	}
+	return settings
+}
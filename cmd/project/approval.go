@@ -0,0 +1,549 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ApprovalTemplate is the approval configuration applied to all projects of a namespace, read from a YAML file.
+type ApprovalTemplate struct {
+	Settings approvalTemplateSettings `yaml:"settings"`
+	Rules    []approvalTemplateRule   `yaml:"rules"`
+}
+
+type approvalTemplateSettings struct {
+	ApprovalsBeforeMerge       *int  `yaml:"approvals-before-merge"`
+	ResetApprovalsOnPush       *bool `yaml:"reset-approvals-on-push"`
+	PreventAuthorApproval      *bool `yaml:"prevent-author-approval"`
+	PreventCommittersApproval  *bool `yaml:"prevent-committers-approval"`
+	DisableOverridingApprovers *bool `yaml:"disable-overriding-approvers"`
+	RequirePassword            *bool `yaml:"require-password"`
+}
+
+type approvalTemplateRule struct {
+	Name              string   `yaml:"name"`
+	ApprovalsRequired int      `yaml:"approvals-required"`
+	Users             []string `yaml:"users"`
+	Groups            []string `yaml:"groups"`
+	ProtectedBranches []string `yaml:"protected-branches"`
+}
+
+// ApprovalChange is a change to the approval configuration of a project, which is made by calling Apply.
+type ApprovalChange struct {
+	Project     lab.Project
+	Description string
+	Apply       func() error
+}
+
+func readApprovalTemplate(path string) (ApprovalTemplate, error) {
+	var template ApprovalTemplate
+	b, err := ioutil.ReadFile(path)
+	if nil != err {
+		return template, err
+	}
+	if err = yaml.UnmarshalStrict(b, &template); nil != err {
+		return template, err
+	}
+	names := map[string]bool{}
+	for _, r := range template.Rules {
+		if "" == r.Name {
+			return template, fmt.Errorf("%s has a rule without a name", path)
+		}
+		if names[r.Name] {
+			return template, fmt.Errorf("%s has multiple rules named %s", path, r.Name)
+		}
+		names[r.Name] = true
+	}
+	return template, nil
+}
+
+func negate(b *bool) *bool {
+	if nil == b {
+		return nil
+	}
+	n := !*b
+	return &n
+}
+
+// settings converts the template settings to the GitLab ones, which allow rather than prevent author approval.
+func (s approvalTemplateSettings) settings() gitlab.ApprovalSettings {
+	return gitlab.ApprovalSettings{
+		ApprovalsBeforeMerge:                      s.ApprovalsBeforeMerge,
+		ResetApprovalsOnPush:                      s.ResetApprovalsOnPush,
+		MergeRequestsAuthorApproval:               negate(s.PreventAuthorApproval),
+		MergeRequestsDisableCommittersApproval:    s.PreventCommittersApproval,
+		DisableOverridingApproversPerMergeRequest: s.DisableOverridingApprovers,
+		RequirePasswordToApprove:                  s.RequirePassword,
+	}
+}
+
+func (r approvalTemplateRule) settings() gitlab.ApprovalRuleSettings {
+	approvalsRequired := r.ApprovalsRequired
+	users := append([]string{}, r.Users...)
+	groups := append([]string{}, r.Groups...)
+	branches := append([]string{}, r.ProtectedBranches...)
+	return gitlab.ApprovalRuleSettings{
+		Name:              r.Name,
+		ApprovalsRequired: &approvalsRequired,
+		Users:             &users,
+		Groups:            &groups,
+		ProtectedBranches: &branches,
+	}
+}
+
+func approvalRuleUsers(rule *lab.ProjectApprovalRule) []string {
+	var result []string
+	for _, u := range rule.Users {
+		result = append(result, u.Username)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func approvalRuleGroups(rule *lab.ProjectApprovalRule) []string {
+	var result []string
+	for _, g := range rule.Groups {
+		result = append(result, g.FullPath)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func approvalRuleBranches(rule *lab.ProjectApprovalRule) []string {
+	var result []string
+	for _, b := range rule.ProtectedBranches {
+		result = append(result, b.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func sameStrings(a, b []string) bool {
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return strings.Join(a, "\n") == strings.Join(b, "\n")
+}
+
+// approvalSettingsChanges returns a description of every setting that differs, and the settings to change.
+func approvalSettingsChanges(current *lab.ProjectApprovals, desired gitlab.ApprovalSettings) ([]string, gitlab.ApprovalSettings) {
+	var descriptions []string
+	changes := gitlab.ApprovalSettings{}
+	if nil != desired.ApprovalsBeforeMerge && *desired.ApprovalsBeforeMerge != current.ApprovalsBeforeMerge {
+		descriptions = append(descriptions, fmt.Sprint("approvals-before-merge: ", current.ApprovalsBeforeMerge, " -> ", *desired.ApprovalsBeforeMerge))
+		changes.ApprovalsBeforeMerge = desired.ApprovalsBeforeMerge
+	}
+	bools := []struct {
+		name    string
+		current bool
+		desired *bool
+		change  **bool
+		// inverted settings are described as the opposite of the GitLab setting, as in the template.
+		inverted bool
+	}{
+		{"reset-approvals-on-push", current.ResetApprovalsOnPush, desired.ResetApprovalsOnPush, &changes.ResetApprovalsOnPush, false},
+		{"prevent-author-approval", current.MergeRequestsAuthorApproval, desired.MergeRequestsAuthorApproval, &changes.MergeRequestsAuthorApproval, true},
+		{"prevent-committers-approval", current.MergeRequestsDisableCommittersApproval, desired.MergeRequestsDisableCommittersApproval, &changes.MergeRequestsDisableCommittersApproval, false},
+		{"disable-overriding-approvers", current.DisableOverridingApproversPerMergeRequest, desired.DisableOverridingApproversPerMergeRequest, &changes.DisableOverridingApproversPerMergeRequest, false},
+		{"require-password", current.RequirePasswordToApprove, desired.RequirePasswordToApprove, &changes.RequirePasswordToApprove, false},
+	}
+	for _, b := range bools {
+		if nil != b.desired && *b.desired != b.current {
+			descriptions = append(descriptions, fmt.Sprint(b.name, ": ", b.current != b.inverted, " -> ", *b.desired != b.inverted))
+			*b.change = b.desired
+		}
+	}
+	return descriptions, changes
+}
+
+// approvalTemplateChanges returns the changes needed to make the approval configuration of the project match the template.
+// Rules are matched by name, and with prune the rules not in the template are removed.
+func approvalTemplateChanges(c *gitlab.GitlabClient, p lab.Project, template ApprovalTemplate, prune bool) ([]ApprovalChange, error) {
+	var result []ApprovalChange
+	current, err := c.ApprovalConfiguration(p)
+	if nil != err {
+		return result, err
+	}
+	descriptions, settings := approvalSettingsChanges(current, template.Settings.settings())
+	if settings.HasChanges() {
+		result = append(result, ApprovalChange{
+			Project:     p,
+			Description: strings.Join(descriptions, ", "),
+			Apply:       func() error { return c.SetApprovalConfiguration(p, settings) },
+		})
+	}
+
+	rules, err := c.ApprovalRules(p)
+	if nil != err {
+		return result, err
+	}
+	existing := map[string]*lab.ProjectApprovalRule{}
+	for _, r := range rules {
+		existing[r.Name] = r
+	}
+	for _, tr := range template.Rules {
+		s := tr.settings()
+		rule, ok := existing[tr.Name]
+		if !ok {
+			result = append(result, ApprovalChange{
+				Project:     p,
+				Description: fmt.Sprint("create rule ", tr.Name),
+				Apply:       func() error { return c.CreateApprovalRule(p, s) },
+			})
+			continue
+		}
+		var changed []string
+		if rule.ApprovalsRequired != tr.ApprovalsRequired {
+			changed = append(changed, "approvals")
+		}
+		if !sameStrings(approvalRuleUsers(rule), tr.Users) {
+			changed = append(changed, "users")
+		}
+		if !sameStrings(approvalRuleGroups(rule), tr.Groups) {
+			changed = append(changed, "groups")
+		}
+		if !sameStrings(approvalRuleBranches(rule), tr.ProtectedBranches) {
+			changed = append(changed, "protected branches")
+		}
+		if len(changed) > 0 {
+			result = append(result, ApprovalChange{
+				Project:     p,
+				Description: fmt.Sprint("update rule ", tr.Name, ": ", strings.Join(changed, ", ")),
+				Apply:       func() error { return c.UpdateApprovalRule(p, *rule, s) },
+			})
+		}
+		delete(existing, tr.Name)
+	}
+	if prune {
+		for _, r := range rules {
+			if _, ok := existing[r.Name]; !ok {
+				continue
+			}
+			rule := r
+			result = append(result, ApprovalChange{
+				Project:     p,
+				Description: fmt.Sprint("remove rule ", rule.Name),
+				Apply:       func() error { return c.RemoveApprovalRule(p, *rule) },
+			})
+		}
+	}
+	return result, nil
+}
+
+// findApprovalRule returns the approval rule of the project with the given name.
+func findApprovalRule(c *gitlab.GitlabClient, p lab.Project, name string) *lab.ProjectApprovalRule {
+	rules, err := c.ApprovalRules(p)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	for _, r := range rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	log.Fatal(fmt.Errorf("No approval rule %s found", name))
+	return nil
+}
+
+func approvalProject(c *gitlab.GitlabClient, namespace, name string) *lab.Project {
+	p, err := c.Project(namespace, name)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	if nil == p {
+		log.Fatal(errors.New("No project found"))
+	}
+	return p
+}
+
+var flagApprovalsBeforeMerge int
+var flagResetApprovalsOnPush bool
+var flagPreventAuthorApproval bool
+var flagPreventCommittersApproval bool
+var flagDisableOverridingApprovers bool
+var flagRequirePassword bool
+
+var flagApprovalRuleApprovals int
+var flagApprovalRuleUsers []string
+var flagApprovalRuleGroups []string
+var flagApprovalRuleBranches []string
+var flagApprovalRuleRename string
+
+var flagApprovalTemplate string
+var flagApprovalPrune bool
+var flagApprovalDryRun bool
+var flagApprovalYes bool
+
+// approvalRuleSettings returns the rule settings given with flags, only including the changed ones.
+func approvalRuleSettings(cmd *cobra.Command, name string) gitlab.ApprovalRuleSettings {
+	settings := gitlab.ApprovalRuleSettings{Name: name}
+	if cmd.Flag("approvals").Changed {
+		settings.ApprovalsRequired = &flagApprovalRuleApprovals
+	}
+	if cmd.Flag("users").Changed {
+		settings.Users = &flagApprovalRuleUsers
+	}
+	if cmd.Flag("groups").Changed {
+		settings.Groups = &flagApprovalRuleGroups
+	}
+	if cmd.Flag("branches").Changed {
+		settings.ProtectedBranches = &flagApprovalRuleBranches
+	}
+	return settings
+}
+
+func addApprovalRuleFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&flagApprovalRuleApprovals, "approvals", "a", 1, "number of approvals required by the rule")
+	cmd.Flags().StringSliceVarP(&flagApprovalRuleUsers, "users", "u", nil, "usernames of the eligible approvers")
+	cmd.Flags().StringSliceVarP(&flagApprovalRuleGroups, "groups", "g", nil, "full paths of the groups whose members are eligible approvers")
+	cmd.Flags().StringSliceVarP(&flagApprovalRuleBranches, "branches", "b", nil, "only apply the rule to these protected branches")
+}
+
+var approvalRulesCmd = &cobra.Command{
+	Use:   "approval-rules",
+	Short: "Show the approval settings and rules of the given project [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := approvalProject(c, args[0], args[1])
+		approvals, err := c.ApprovalConfiguration(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		rules, err := c.ApprovalRules(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Setting", "Value")
+		tbl.WithHeaderFormatter(headerFmt)
+		tbl.AddRow("approvals-before-merge", approvals.ApprovalsBeforeMerge)
+		tbl.AddRow("reset-approvals-on-push", approvals.ResetApprovalsOnPush)
+		tbl.AddRow("prevent-author-approval", !approvals.MergeRequestsAuthorApproval)
+		tbl.AddRow("prevent-committers-approval", approvals.MergeRequestsDisableCommittersApproval)
+		tbl.AddRow("disable-overriding-approvers", approvals.DisableOverridingApproversPerMergeRequest)
+		tbl.AddRow("require-password", approvals.RequirePasswordToApprove)
+		tbl.Print()
+		fmt.Println()
+
+		if len(rules) == 0 {
+			fmt.Println("No approval rules")
+			return
+		}
+		tbl = table.New("Rule", "Type", "Approvals", "Users", "Groups", "Protected branches")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, r := range rules {
+			tbl.AddRow(
+				r.Name,
+				r.RuleType,
+				strconv.Itoa(r.ApprovalsRequired),
+				strings.Join(approvalRuleUsers(r), ", "),
+				strings.Join(approvalRuleGroups(r), ", "),
+				strings.Join(approvalRuleBranches(r), ", "),
+			)
+		}
+		tbl.Print()
+	},
+}
+
+var approvalSettingsCmd = &cobra.Command{
+	Use:   "approval-settings",
+	Short: "Set the approval settings of the given project [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var template approvalTemplateSettings
+		if cmd.Flag("approvals-before-merge").Changed {
+			template.ApprovalsBeforeMerge = &flagApprovalsBeforeMerge
+		}
+		if cmd.Flag("reset-approvals-on-push").Changed {
+			template.ResetApprovalsOnPush = &flagResetApprovalsOnPush
+		}
+		if cmd.Flag("prevent-author-approval").Changed {
+			template.PreventAuthorApproval = &flagPreventAuthorApproval
+		}
+		if cmd.Flag("prevent-committers-approval").Changed {
+			template.PreventCommittersApproval = &flagPreventCommittersApproval
+		}
+		if cmd.Flag("disable-overriding-approvers").Changed {
+			template.DisableOverridingApprovers = &flagDisableOverridingApprovers
+		}
+		if cmd.Flag("require-password").Changed {
+			template.RequirePassword = &flagRequirePassword
+		}
+		settings := template.settings()
+		if !settings.HasChanges() {
+			log.Fatal(errors.New("No approval settings given"))
+		}
+
+		c := gitlab.NewClient()
+		p := approvalProject(c, args[0], args[1])
+		if err := c.SetApprovalConfiguration(*p, settings); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var approvalRuleCmd = &cobra.Command{
+	Use:   "approval-rule",
+	Short: "Manage the approval rules of a project",
+}
+
+var createApprovalRuleCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an approval rule for the given project [namespace] [project-name] [rule-name]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := approvalProject(c, args[0], args[1])
+		settings := approvalRuleSettings(cmd, args[2])
+		settings.ApprovalsRequired = &flagApprovalRuleApprovals
+		if err := c.CreateApprovalRule(*p, settings); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var updateApprovalRuleCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update an approval rule of the given project [namespace] [project-name] [rule-name]",
+	Long: `Update an approval rule of the given project.
+
+Only the given flags are changed. Lists replace the current ones, so pass an
+empty value, such as --users "", to remove all users.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := approvalProject(c, args[0], args[1])
+		rule := findApprovalRule(c, *p, args[2])
+		settings := approvalRuleSettings(cmd, flagApprovalRuleRename)
+		if err := c.UpdateApprovalRule(*p, *rule, settings); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var deleteApprovalRuleCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete an approval rule of the given project [namespace] [project-name] [rule-name]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := approvalProject(c, args[0], args[1])
+		rule := findApprovalRule(c, *p, args[2])
+		if err := c.RemoveApprovalRule(*p, *rule); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var approvalsForNamespaceCmd = &cobra.Command{
+	Use:   "approvals-for-namespace",
+	Short: "Apply an approval template to all projects in [namespace]",
+	Long: `Apply an approval template to all projects in a namespace.
+
+The template is a YAML file such as:
+
+  settings:
+    approvals-before-merge: 1
+    reset-approvals-on-push: true
+    prevent-author-approval: true
+  rules:
+    - name: Maintainers
+      approvals-required: 1
+      groups: [my-group/maintainers]
+      protected-branches: [main]
+
+Settings missing from the template are left as they are. Rules are matched by
+name, and with --prune rules not in the template are removed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		template, err := readApprovalTemplate(flagApprovalTemplate)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var changes []ApprovalChange
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			projectChanges, err := approvalTemplateChanges(c, p, template, flagApprovalPrune)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			changes = append(changes, projectChanges...)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Project", "Change")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, change := range changes {
+			tbl.AddRow(change.Project.PathWithNamespace, change.Description)
+		}
+		tbl.Print()
+		if flagApprovalDryRun {
+			return
+		}
+		if !confirm(fmt.Sprint("Apply ", len(changes), " changes"), flagApprovalYes) {
+			return
+		}
+
+		for _, change := range changes {
+			if err = change.Apply(); nil != err {
+				log.Fatal(fmt.Sprint(change.Project.PathWithNamespace, ": ", err.Error()))
+			}
+		}
+	},
+}
+
+func init() {
+	approvalSettingsCmd.Flags().IntVar(&flagApprovalsBeforeMerge, "approvals-before-merge", 0, "number of approvals required before merging")
+	approvalSettingsCmd.Flags().BoolVar(&flagResetApprovalsOnPush, "reset-approvals-on-push", false, "remove all approvals when new commits are pushed")
+	approvalSettingsCmd.Flags().BoolVar(&flagPreventAuthorApproval, "prevent-author-approval", false, "prevent the author from approving their merge request")
+	approvalSettingsCmd.Flags().BoolVar(&flagPreventCommittersApproval, "prevent-committers-approval", false, "prevent committers from approving a merge request")
+	approvalSettingsCmd.Flags().BoolVar(&flagDisableOverridingApprovers, "disable-overriding-approvers", false, "prevent editing approval rules in merge requests")
+	approvalSettingsCmd.Flags().BoolVar(&flagRequirePassword, "require-password", false, "require the user password to approve")
+
+	addApprovalRuleFlags(createApprovalRuleCmd)
+	addApprovalRuleFlags(updateApprovalRuleCmd)
+	updateApprovalRuleCmd.Flags().StringVar(&flagApprovalRuleRename, "rename", "", "new name of the rule")
+
+	approvalsForNamespaceCmd.Flags().StringVarP(&flagApprovalTemplate, "template", "t", "", "YAML file with the approval settings and rules")
+	approvalsForNamespaceCmd.Flags().BoolVar(&flagApprovalPrune, "prune", false, "remove approval rules not in the template")
+	approvalsForNamespaceCmd.Flags().BoolVar(&flagApprovalDryRun, "dry-run", false, "only show the changes")
+	approvalsForNamespaceCmd.Flags().BoolVarP(&flagApprovalYes, "yes", "y", false, "apply the changes without asking for confirmation")
+	approvalsForNamespaceCmd.MarkFlagRequired("template")
+
+	approvalRuleCmd.AddCommand(createApprovalRuleCmd)
+	approvalRuleCmd.AddCommand(updateApprovalRuleCmd)
+	approvalRuleCmd.AddCommand(deleteApprovalRuleCmd)
+
+	projectCmd.AddCommand(approvalRulesCmd)
+	projectCmd.AddCommand(approvalSettingsCmd)
+	projectCmd.AddCommand(approvalRuleCmd)
+	projectCmd.AddCommand(approvalsForNamespaceCmd)
+}
@@ -0,0 +1,202 @@
+package project
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+// ArtifactFile is a file written from an artifacts archive.
+type ArtifactFile struct {
+	Path string
+	Size int64
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprint(size, " B")
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// writeArtifactFile writes a file of the archive to path.
+func writeArtifactFile(f *zip.File, path string) (ArtifactFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+		return ArtifactFile{}, err
+	}
+	r, err := f.Open()
+	if nil != err {
+		return ArtifactFile{}, err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.Mode().Perm()|0200)
+	if nil != err {
+		return ArtifactFile{}, err
+	}
+	size, err := io.Copy(w, r)
+	if nil != err {
+		w.Close()
+		return ArtifactFile{}, err
+	}
+	return ArtifactFile{Path: path, Size: size}, w.Close()
+}
+
+// writeArtifactArchive writes the archive as is to a new file at path. An existing file is never overwritten.
+func writeArtifactArchive(archive io.Reader, path string) (ArtifactFile, error) {
+	w, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if nil != err {
+		return ArtifactFile{}, err
+	}
+	size, err := io.Copy(w, archive)
+	if nil != err {
+		w.Close()
+		return ArtifactFile{}, err
+	}
+	return ArtifactFile{Path: path, Size: size}, w.Close()
+}
+
+// extractArtifacts writes the files of the archive to dir. When only is given, just that file is written, directly in dir.
+func extractArtifacts(archive *bytes.Reader, dir, only string) ([]ArtifactFile, error) {
+	var result []ArtifactFile
+	zr, err := zip.NewReader(archive, archive.Size())
+	if nil != err {
+		return result, err
+	}
+	if "" == only {
+		// Archives could contain paths like ../../.bashrc, which should never be written outside dir.
+		for _, f := range zr.File {
+			if rel, err := filepath.Rel(dir, filepath.Join(dir, f.Name)); nil != err || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return result, fmt.Errorf("%s is outside of the output directory", f.Name)
+			}
+		}
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if "" != only {
+			if f.Name != only {
+				continue
+			}
+			written, err := writeArtifactFile(f, filepath.Join(dir, filepath.Base(f.Name)))
+			return append(result, written), err
+		}
+		written, err := writeArtifactFile(f, filepath.Join(dir, f.Name))
+		if nil != err {
+			return result, err
+		}
+		result = append(result, written)
+	}
+	if "" != only {
+		return result, fmt.Errorf("No file %s found in the artifacts", only)
+	}
+	return result, nil
+}
+
+var flagArtifactsLatest string
+var flagArtifactsFile string
+var flagArtifactsOutput string
+var flagArtifactsArchive bool
+
+var artifactsCmd = &cobra.Command{
+	Use:   "artifacts",
+	Short: "Manage the job artifacts of a project",
+}
+
+var downloadArtifactsCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download the artifacts of a job of the given project [namespace] [project-name] [job-name|job-id]",
+	Long: `Download the artifacts of a job of the given project, and extract them into
+the output directory.
+
+The job is looked up by name in the pipeline given with --pipeline, or with
+--latest-successful in the latest successful pipeline for a branch or tag. A
+number is taken as a job name first, and as a job ID otherwise. Use --file to
+only extract a single file, or --archive to keep the archive as is, which never
+overwrites an existing archive.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if "" != flagArtifactsFile && flagArtifactsArchive {
+			log.Fatal(errors.New("Either give --file or --archive, not both"))
+		}
+		if "" != flagArtifactsLatest && "" != flagJobPipeline {
+			log.Fatal(errors.New("Either give --pipeline or --latest-successful, not both"))
+		}
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+
+		var archive *bytes.Reader
+		var err error
+		name := args[2]
+		if "" != flagArtifactsLatest {
+			archive, err = c.LatestArtifacts(*p, flagArtifactsLatest, name)
+		} else {
+			job := resolveJob(c, *p, args[2])
+			name = fmt.Sprint(job.Name, "-", job.ID)
+			archive, err = c.JobArtifacts(*p, job.ID)
+		}
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Println("Downloaded", formatSize(archive.Size()))
+
+		var files []ArtifactFile
+		if flagArtifactsArchive {
+			// Names of parallel jobs look like rspec 1/3.
+			fileName := strings.NewReplacer("/", "-", " ", "-").Replace(name)
+			path := filepath.Join(flagArtifactsOutput, fmt.Sprint(fileName, "-artifacts.zip"))
+			if err = os.MkdirAll(flagArtifactsOutput, 0755); nil != err {
+				log.Fatal(err.Error())
+			}
+			file, err := writeArtifactArchive(archive, path)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			files = append(files, file)
+		} else {
+			files, err = extractArtifacts(archive, flagArtifactsOutput, flagArtifactsFile)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("File", "Size")
+		tbl.WithHeaderFormatter(headerFmt)
+		var total int64
+		for _, f := range files {
+			tbl.AddRow(f.Path, formatSize(f.Size))
+			total += f.Size
+		}
+		tbl.Print()
+		fmt.Println(len(files), "files,", formatSize(total), "written to", flagArtifactsOutput)
+	},
+}
+
+func init() {
+	downloadArtifactsCmd.Flags().StringVarP(&flagJobPipeline, "pipeline", "p", "", "branch, tag or pipeline ID to find the job by name in, defaults to the default branch")
+	downloadArtifactsCmd.Flags().StringVarP(&flagArtifactsLatest, "latest-successful", "l", "", "use the job in the latest successful pipeline for this branch or tag")
+	downloadArtifactsCmd.Flags().StringVarP(&flagArtifactsFile, "file", "f", "", "only extract this file of the archive")
+	downloadArtifactsCmd.Flags().StringVarP(&flagArtifactsOutput, "output", "o", ".", "directory to write the artifacts to")
+	downloadArtifactsCmd.Flags().BoolVarP(&flagArtifactsArchive, "archive", "a", false, "write the archive instead of extracting it")
+
+	artifactsCmd.AddCommand(downloadArtifactsCmd)
+	projectCmd.AddCommand(artifactsCmd)
+}
@@ -0,0 +1,99 @@
+package project
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); nil != err {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b.Bytes())
+}
+
+func TestExtractArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	files, err := extractArtifacts(zipArchive(t, map[string]string{
+		"report.xml":         "<testsuites/>",
+		"..foo/bar":          "not outside",
+		"coverage/lcov.info": "TN:",
+	}), dir, "")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files, got %v", files)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "..foo", "bar"))
+	if nil != err || string(content) != "not outside" {
+		t.Errorf("expected ..foo/bar to be extracted, got %q, %v", content, err)
+	}
+}
+
+func TestExtractArtifactsOnly(t *testing.T) {
+	dir := t.TempDir()
+	archive := zipArchive(t, map[string]string{"coverage/lcov.info": "TN:", "report.xml": "<testsuites/>"})
+	files, err := extractArtifacts(archive, dir, "coverage/lcov.info")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != filepath.Join(dir, "lcov.info") {
+		t.Errorf("expected only lcov.info, got %v", files)
+	}
+	if _, err = extractArtifacts(archive, dir, "missing.txt"); nil == err {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestExtractArtifactsOutsideDir(t *testing.T) {
+	for _, name := range []string{"../evil", "../../.bashrc", "a/../../evil", ".."} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "out")
+			files, err := extractArtifacts(zipArchive(t, map[string]string{"ok.txt": "ok", name: "evil"}), dir, "")
+			if nil == err {
+				t.Errorf("expected %s to be rejected", name)
+			}
+			if len(files) != 0 {
+				t.Errorf("expected nothing to be written, got %v", files)
+			}
+			if entries, _ := ioutil.ReadDir(parent); len(entries) != 0 {
+				t.Errorf("expected nothing to be written next to the output directory, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteArtifactArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job-artifacts.zip")
+	file, err := writeArtifactArchive(bytes.NewReader([]byte("first")), path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if file.Size != 5 {
+		t.Errorf("expected 5 bytes to be written, got %d", file.Size)
+	}
+	if _, err = writeArtifactArchive(bytes.NewReader([]byte("second")), path); nil == err {
+		t.Error("expected an existing archive not to be overwritten")
+	}
+	content, err := ioutil.ReadFile(path)
+	if nil != err || string(content) != "first" {
+		t.Errorf("expected the first archive to be kept, got %q, %v", content, err)
+	}
+}
@@ -4,10 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/manifoldco/promptui"
+	"github.com/fatih/color"
 	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
 )
 
 var getBranchCmd = &cobra.Command{
@@ -28,14 +37,138 @@ var getBranchCmd = &cobra.Command{
 		if nil != err {
 			log.Fatal(err.Error())
 		}
-		fmt.Println(branch.Name)
-		fmt.Println(branch.Protected)
+
+		var statuses []BranchStatus
+		if flagBranchStatus {
+			statuses, err = branchStatuses(c, *project, []lab.Branch{*branch}, true)
+		} else {
+			statuses, err = branchesWithOpenMergeRequests(c, *project, []lab.Branch{*branch})
+		}
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printBranchStatuses(statuses, flagBranchStatus)
 	},
 }
 
+// printBranchStatuses prints the branches with their open merge request, with the ahead and behind columns only if withStatus
+// is set.
+func printBranchStatuses(statuses []BranchStatus, withStatus bool) {
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	var tbl table.Table
+	if withStatus {
+		tbl = table.New("Branch", "Commit", "Author", "Committed", "Protected", "Default", "Merged", "Ahead", "Behind", "Developers can push", "Open MR")
+	} else {
+		tbl = table.New("Branch", "Commit", "Author", "Committed", "Protected", "Default", "Merged", "Developers can push", "Open MR")
+	}
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, s := range statuses {
+		b := s.Branch
+		sha, author, committed := "", "", ""
+		if nil != b.Commit {
+			sha = b.Commit.ShortID
+			author = b.Commit.AuthorName
+			if nil != b.Commit.CommittedDate {
+				committed = b.Commit.CommittedDate.Format("2006-01-02 15:04:05")
+			}
+		}
+		openMR := ""
+		if nil != s.OpenMergeRequest {
+			openMR = s.OpenMergeRequest.WebURL
+		}
+		if withStatus {
+			tbl.AddRow(b.Name, sha, author, committed, b.Protected, b.Default, s.Merged, s.Ahead, s.Behind, b.DevelopersCanPush, openMR)
+		} else {
+			tbl.AddRow(b.Name, sha, author, committed, b.Protected, b.Default, s.Merged, b.DevelopersCanPush, openMR)
+		}
+	}
+	tbl.Print()
+}
+
+func branchCommittedDate(b lab.Branch) time.Time {
+	if nil == b.Commit || nil == b.Commit.CommittedDate {
+		return time.Time{}
+	}
+	return *b.Commit.CommittedDate
+}
+
+func branchAuthor(b lab.Branch) string {
+	if nil == b.Commit {
+		return ""
+	}
+	return b.Commit.AuthorName
+}
+
+// filterBranches keeps the branches matching the listing flags, which only need the branch itself to be checked.
+func filterBranches(branches []lab.Branch) ([]lab.Branch, error) {
+	var olderThan, newerThan time.Duration
+	var err error
+	if "" != flagBranchesOlderThan {
+		if olderThan, err = parseAge(flagBranchesOlderThan); nil != err {
+			return nil, err
+		}
+	}
+	if "" != flagBranchesNewerThan {
+		if newerThan, err = parseAge(flagBranchesNewerThan); nil != err {
+			return nil, err
+		}
+	}
+
+	var name []*regexp.Regexp
+	if "" != flagBranchesName {
+		if name, err = compileGlobs([]string{flagBranchesName}); nil != err {
+			return nil, err
+		}
+	}
+
+	var result []lab.Branch
+	for _, b := range branches {
+		if nil != name && !matchesAnyGlob(name, b.Name) {
+			continue
+		}
+		author := strings.ToLower(flagBranchesAuthor)
+		if "" != author && !strings.Contains(strings.ToLower(branchAuthor(b)), author) {
+			continue
+		}
+		age := time.Since(branchCommittedDate(b))
+		if 0 != olderThan && age < olderThan {
+			continue
+		}
+		if 0 != newerThan && age > newerThan {
+			continue
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+func sortBranches(branches []lab.Branch, by string) error {
+	var less func(i, j int) bool
+	switch by {
+	case "name":
+		less = func(i, j int) bool { return branches[i].Name < branches[j].Name }
+	case "date":
+		less = func(i, j int) bool { return branchCommittedDate(branches[i]).After(branchCommittedDate(branches[j])) }
+	case "author":
+		less = func(i, j int) bool { return branchAuthor(branches[i]) < branchAuthor(branches[j]) }
+	default:
+		return fmt.Errorf("non valid sort %s given", by)
+	}
+	sort.SliceStable(branches, less)
+	return nil
+}
+
+var flagBranchesSort string
+var flagBranchesAuthor string
+var flagBranchesName string
+var flagBranchesOlderThan string
+var flagBranchesNewerThan string
+var flagBranchesStatus bool
+var flagBranchStatus bool
+
 var getBranchListCmd = &cobra.Command{
 	Use:   "branches",
-	Short: "Get a branch of the given project [namespace] [project-name]",
+	Short: "Get the branches of the given project [namespace] [project-name]",
 	Args:  cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		c := gitlab.NewClient()
@@ -51,10 +184,193 @@ var getBranchListCmd = &cobra.Command{
 		if nil != err {
 			log.Fatal(err.Error())
 		}
+		branches, err = filterBranches(branches)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if err = sortBranches(branches, flagBranchesSort); nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var statuses []BranchStatus
+		if flagBranchesStatus {
+			statuses, err = branchStatuses(c, *project, branches, true)
+		} else {
+			statuses, err = branchesWithOpenMergeRequests(c, *project, branches)
+		}
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printBranchStatuses(statuses, flagBranchesStatus)
+	},
+}
+
+var createBranchCmd = &cobra.Command{
+	Use:   "create-branch",
+	Short: "Create a branch in the given project [namespace] [project-name] [branch-name] [ref-or-sha]",
+	Args:  cobra.ExactArgs(4),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		project, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == project {
+			log.Fatal(errors.New("No project found"))
+		}
 
-		for _, b := range branches {
-			fmt.Println(b.Name)
+		branch, err := c.CreateBranch(*project, args[2], args[3])
+		if nil != err {
+			log.Fatal(err.Error())
 		}
+		fmt.Println("Branch", branch.Name, "created at", branch.Commit.ID)
+	},
+}
+
+// wildcardPattern compiles the name of a protected branch, in which GitLab only treats * as a wildcard.
+func wildcardPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(name), `\*`, ".*") + "$")
+}
+
+// branchProtection returns the protection of the branch by its exact name, or else the first protection by a wildcard matching
+// it, or nil if it is not protected.
+func branchProtection(protections []*lab.ProtectedBranch, name string) *lab.ProtectedBranch {
+	var wildcard *lab.ProtectedBranch
+	for _, pb := range protections {
+		if pb.Name == name {
+			return pb
+		}
+		if nil == wildcard && strings.Contains(pb.Name, "*") && wildcardPattern(pb.Name).MatchString(name) {
+			wildcard = pb
+		}
+	}
+	return wildcard
+}
+
+var flagRenameRecreateMergeRequests bool
+
+var renameBranchCmd = &cobra.Command{
+	Use:   "rename-branch",
+	Short: "Rename a branch of the given project [namespace] [project-name] [branch-name] [new-branch-name]",
+	Long: `Rename a branch of the given project.
+
+Creates the new branch at the commit of the old one, moves its protection and
+default branch status, and retargets open merge requests targeting the old
+branch. Protection by a wildcard that does not cover the new name is copied to
+the new branch.
+
+GitLab does not allow changing the source branch of a merge request, so open
+merge requests from the old branch keep the old branch unless
+--recreate-merge-requests is given. That recreates them from the new branch and
+closes the originals with a note linking to their replacement, leaving their
+discussions and approvals behind on the closed ones.
+
+The old branch is only removed when every step succeeded. Otherwise the steps
+that failed are listed, the old branch is kept and the command exits non-zero.`,
+	Args: cobra.ExactArgs(4),
+	Run: func(cmd *cobra.Command, args []string) {
+		oldName, newName := args[2], args[3]
+		c := gitlab.NewClient()
+		project, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == project {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		old, err := c.Branch(*project, oldName)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		protections, err := c.ProtectedBranches(*project)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		mrs, err := c.ProjectMergeRequests(*project, gitlab.Open)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		var targetMRs, sourceMRs []*lab.MergeRequest
+		for _, mr := range mrs {
+			if mr.TargetBranch == oldName {
+				targetMRs = append(targetMRs, mr)
+			}
+			if mr.SourceBranch == oldName && mr.SourceProjectID == project.ID {
+				sourceMRs = append(sourceMRs, mr)
+			}
+		}
+		if len(sourceMRs) > 0 && flagRenameRecreateMergeRequests {
+			color.New(color.FgYellow).Println("Warning: recreating", len(sourceMRs), "merge requests closes the originals, their discussions and approvals are not carried over")
+		}
+
+		if _, err = c.CreateBranch(*project, newName, old.Commit.ID); nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Println("Branch", newName, "created at", old.Commit.ID)
+
+		// Every step after creating the branch is attempted, so a failure can be recovered from by hand.
+		var failures []string
+		protection := branchProtection(protections, oldName)
+		switch {
+		case nil == protection:
+		case protection.Name != oldName && protection == branchProtection(protections, newName):
+			fmt.Println("Branch", newName, "is protected by", protection.Name, "like", oldName)
+		default:
+			if err = c.CopyBranchProtection(*project, *protection, newName); nil != err {
+				failures = append(failures, fmt.Sprint("protecting ", newName, " like ", protection.Name, ": ", err.Error()))
+			} else {
+				fmt.Println("Protection of", protection.Name, "copied to", newName)
+			}
+		}
+
+		if old.Default {
+			if err = c.SetDefaultBranch(*project, newName); nil != err {
+				failures = append(failures, fmt.Sprint("setting the default branch to ", newName, ": ", err.Error()))
+			} else {
+				fmt.Println("Default branch set to", newName)
+			}
+		}
+
+		for _, mr := range targetMRs {
+			if err = c.RetargetMergeRequest(*project, *mr, newName); nil != err {
+				failures = append(failures, fmt.Sprint("retargeting merge request ", mr.WebURL, ": ", err.Error()))
+				continue
+			}
+			fmt.Println("Merge request", mr.WebURL, "retargeted to", newName)
+		}
+		for _, mr := range sourceMRs {
+			if !flagRenameRecreateMergeRequests {
+				failures = append(failures, fmt.Sprint("merge request ", mr.WebURL, " is from ", oldName, ", recreate it from ", newName, " by hand"))
+				continue
+			}
+			created, err := c.RecreateMergeRequest(*project, *mr, newName)
+			if nil != err {
+				failures = append(failures, fmt.Sprint("recreating merge request ", mr.WebURL, ": ", err.Error()))
+				continue
+			}
+			fmt.Println("Merge request", mr.WebURL, "replaced by", created.WebURL)
+		}
+
+		if len(failures) == 0 && nil != protection && protection.Name == oldName {
+			if err = c.RemoveBranchProtection(*project, oldName); nil != err {
+				failures = append(failures, fmt.Sprint("unprotecting ", oldName, ": ", err.Error()))
+			}
+		}
+		if len(failures) == 0 {
+			if err = c.RemoveBranch(*project, *old); nil != err {
+				failures = append(failures, fmt.Sprint("removing ", oldName, ": ", err.Error()))
+			}
+		}
+		if len(failures) > 0 {
+			fmt.Println("Branch", oldName, "was kept, as the rename to", newName, "is incomplete:")
+			for _, f := range failures {
+				fmt.Println("  -", f)
+			}
+			fmt.Println("Once these are resolved, remove", oldName, "by hand.")
+			os.Exit(1)
+		}
+		fmt.Println("Branch", oldName, "removed")
 	},
 }
 
@@ -104,11 +420,176 @@ var unprotectCmd = &cobra.Command{
 	},
 }
 
+type BranchCriteria struct {
+	OlderThan      time.Duration
+	MergedInto     string
+	Exclude        []*regexp.Regexp
+	SkipProtected  bool
+	SkipWithOpenMR bool
+}
+
+// parseAge parses a duration that besides the units of time.ParseDuration also accepts days and weeks, such as 90d or 2w.
+// Ages that are not positive are rejected, as they would match every branch.
+func parseAge(s string) (time.Duration, error) {
+	age, err := parseDuration(s)
+	if nil != err {
+		return 0, err
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("invalid age %s, it should be more than zero", s)
+	}
+	return age, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+		if nil != err {
+			return 0, fmt.Errorf("invalid age %s", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatAge formats a duration in whole days if it is longer than a day.
+func formatAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Round(time.Minute).String()
+	}
+	return fmt.Sprint(int(d/(24*time.Hour)), "d")
+}
+
+// branchMergedInto tells whether the branch has no commits that are not in target, which is "default" for the default branch.
+func branchMergedInto(c *gitlab.GitlabClient, p lab.Project, s BranchStatus, target string) (bool, error) {
+	if target == "default" || target == p.DefaultBranch {
+		return s.Merged, nil
+	}
+	compare, err := c.Compare(p, target, s.Branch.Name)
+	if nil != err {
+		return false, err
+	}
+	return len(compare.Commits) == 0, nil
+}
+
+// needsStatus tells whether checking the criteria takes the status of the branches, which takes several requests per branch.
+func (criteria BranchCriteria) needsStatus() bool {
+	return "" != criteria.MergedInto || criteria.SkipWithOpenMR
+}
+
+// branchSkipReason returns why the branch should not be pruned given the criteria that can be checked on the branch itself,
+// or an empty string if it should.
+func branchSkipReason(b lab.Branch, criteria BranchCriteria) string {
+	if b.Default {
+		return "default branch"
+	}
+	if matchesAnyGlob(criteria.Exclude, b.Name) {
+		return "excluded"
+	}
+	if criteria.SkipProtected && b.Protected {
+		return "protected"
+	}
+	if 0 != criteria.OlderThan && nil != b.Commit && nil != b.Commit.CommittedDate && time.Since(*b.Commit.CommittedDate) < criteria.OlderThan {
+		return fmt.Sprint("committed to within ", formatAge(criteria.OlderThan))
+	}
+	return ""
+}
+
+// statusSkipReason returns why the branch should not be pruned given the criteria that need its status, or an empty string if it should.
+func statusSkipReason(c *gitlab.GitlabClient, p lab.Project, s BranchStatus, criteria BranchCriteria) (string, error) {
+	if criteria.SkipWithOpenMR && nil != s.OpenMergeRequest {
+		return fmt.Sprint("open merge request ", s.OpenMergeRequest.WebURL), nil
+	}
+	if "" != criteria.MergedInto {
+		merged, err := branchMergedInto(c, p, s, criteria.MergedInto)
+		if nil != err {
+			return "", err
+		}
+		if !merged {
+			return fmt.Sprint("not merged into ", criteria.MergedInto), nil
+		}
+	}
+	return "", nil
+}
+
+// staleBranches returns the branches with why each should not be pruned given the criteria, or an empty string if it should.
+// The status is only resolved for the branches that are not skipped on the branch itself, and only if the criteria or withStatus
+// ask for it.
+func staleBranches(c *gitlab.GitlabClient, p lab.Project, branches []lab.Branch, criteria BranchCriteria, withStatus bool) ([]BranchStatus, []string, error) {
+	statuses := plainBranchStatuses(branches)
+	reasons := make([]string, len(branches))
+	var candidates []int
+	var candidateBranches []lab.Branch
+	for i, b := range branches {
+		if reasons[i] = branchSkipReason(b, criteria); "" == reasons[i] {
+			candidates = append(candidates, i)
+			candidateBranches = append(candidateBranches, b)
+		}
+	}
+	if len(candidates) == 0 || !(withStatus || criteria.needsStatus()) {
+		return statuses, reasons, nil
+	}
+
+	resolved, err := branchStatuses(c, p, candidateBranches, false)
+	if nil != err {
+		return nil, nil, err
+	}
+	for j, i := range candidates {
+		statuses[i] = resolved[j]
+		if reasons[i], err = statusSkipReason(c, p, resolved[j], criteria); nil != err {
+			return nil, nil, err
+		}
+	}
+	return statuses, reasons, nil
+}
+
+func branchCriteria() BranchCriteria {
+	criteria := BranchCriteria{
+		MergedInto:     flagCriteriaMergedInto,
+		SkipProtected:  flagCriteriaSkipProtected,
+		SkipWithOpenMR: flagCriteriaSkipWithOpenMR,
+	}
+	exclude, err := compileGlobs(flagCriteriaExclude)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	criteria.Exclude = exclude
+	if "" != flagCriteriaOlderThan {
+		olderThan, err := parseAge(flagCriteriaOlderThan)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		criteria.OlderThan = olderThan
+	}
+	return criteria
+}
+
+var flagCriteriaOlderThan string
+var flagCriteriaMergedInto string
+var flagCriteriaExclude []string
+var flagCriteriaSkipProtected bool
+var flagCriteriaSkipWithOpenMR bool
+
+var flagPruneYes bool
+var flagPruneDryRun bool
+
 var pruneStaleBranchesCmd = &cobra.Command{
 	Use:   "prune-stale-branches",
 	Short: "Prune stale branches of the given project [namespace] [project-name]",
-	Args:  cobra.ExactArgs(2),
+	Long: `Prune stale branches of the given project.
+
+Every non-default branch matching the criteria is a candidate. Branches are only
+compared with the default branch when --merged-into or --skip-with-open-mr is
+given, and then only the branches not skipped already. Candidates are
+confirmed one by one, unless --yes or --dry-run is given. Branches that fail to
+be removed, such as protected ones, are reported and exit non-zero.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
+		criteria := branchCriteria()
+
 		c := gitlab.NewClient()
 		project, err := c.Project(args[0], args[1])
 		if nil != err {
@@ -122,34 +603,83 @@ var pruneStaleBranchesCmd = &cobra.Command{
 		if nil != err {
 			log.Fatal(err.Error())
 		}
+		sort.Slice(branches, func(i, j int) bool {
+			return branches[i].Name < branches[j].Name
+		})
 
-		for _, b := range branches {
-			if b.Default == true {
-				continue
-			}
-			cmt := b.Commit
+		statuses, reasons, err := staleBranches(c, *project, branches, criteria, false)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
 
-			p := promptui.Select{
-				Label: fmt.Sprint("Remove branch ", b.Name, " last commited to at", cmt.CommittedDate.String(), " "),
-				Items: []string{"yes", "no"},
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Branch", "Last commit", "Merged", "Ahead", "Open MR", "Result")
+		tbl.WithHeaderFormatter(headerFmt)
+		deleted, skipped, failed := 0, 0, 0
+		for i, s := range statuses {
+			b := s.Branch
+			cmt := b.Commit
+			committed := ""
+			if nil != cmt && nil != cmt.CommittedDate {
+				committed = cmt.CommittedDate.Format("2006-01-02 15:04:05")
+			}
+			ahead, openMR := "", ""
+			if s.Resolved {
+				ahead = strconv.Itoa(s.Ahead)
+			}
+			if nil != s.OpenMergeRequest {
+				openMR = s.OpenMergeRequest.WebURL
+			}
+			addRow := func(result string) {
+				tbl.AddRow(b.Name, committed, s.Merged, ahead, openMR, result)
 			}
 
-			_, i, err := p.Run()
-			if nil != err {
-				log.Fatal(err)
+			reason := reasons[i]
+			if "" == reason && flagPruneDryRun {
+				addRow("would be removed")
+				deleted++
+				continue
 			}
-			if i == "no" {
+			if "" == reason && !confirm(fmt.Sprint("Remove branch ", b.Name, " last commited to at ", committed, " "), flagPruneYes) {
+				reason = "declined"
+			}
+			if "" != reason {
+				addRow(fmt.Sprint("skipped: ", reason))
+				skipped++
 				continue
 			}
 
+			// Protected branches cannot be removed without --skip-protected, which should not stop the other removals.
 			if err = c.RemoveBranch(*project, b); nil != err {
-				log.Fatal(err)
+				addRow(fmt.Sprint("failed: ", err.Error()))
+				failed++
+				continue
 			}
-			fmt.Println("Branch ", b.Name, " removed")
+			addRow("removed")
+			deleted++
+		}
+
+		tbl.Print()
+		if flagPruneDryRun {
+			fmt.Println(deleted, "branches would be removed,", skipped, "skipped")
+			return
+		}
+		fmt.Println(deleted, "branches removed,", skipped, "skipped,", failed, "failed")
+		if failed > 0 {
+			os.Exit(1)
 		}
 	},
 }
 
+// addBranchCriteriaFlags adds the flags read by branchCriteria, shared by prune-stale-branches and stale-branches.
+func addBranchCriteriaFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&flagCriteriaOlderThan, "older-than", "o", "", "only branches last committed to longer ago than this, such as 90d, 2w or 12h")
+	cmd.Flags().StringVarP(&flagCriteriaMergedInto, "merged-into", "m", "", "only branches merged into this branch, or \"default\" for the default branch")
+	cmd.Flags().StringSliceVarP(&flagCriteriaExclude, "exclude", "x", nil, "skip branches matching these globs")
+	cmd.Flags().BoolVar(&flagCriteriaSkipProtected, "skip-protected", false, "skip protected branches")
+	cmd.Flags().BoolVar(&flagCriteriaSkipWithOpenMR, "skip-with-open-mr", false, "skip branches that are the source of an open merge request")
+}
+
 var unprotectedDefaultBranchesCmd = &cobra.Command{
 	Use:   "unprotected-default-branches",
 	Short: "List any default branches for projects in [namespace]",
@@ -180,8 +710,24 @@ var unprotectedDefaultBranchesCmd = &cobra.Command{
 }
 
 func init() {
+	getBranchListCmd.Flags().StringVarP(&flagBranchesSort, "sort", "s", "name", "sort the branches. [name|date|author]")
+	getBranchListCmd.Flags().StringVarP(&flagBranchesAuthor, "author", "a", "", "only branches whose last commit author contains this")
+	getBranchListCmd.Flags().StringVarP(&flagBranchesName, "name", "n", "", "only branches matching this glob")
+	getBranchListCmd.Flags().StringVar(&flagBranchesOlderThan, "older-than", "", "only branches last committed to longer ago than this, such as 90d, 2w or 12h")
+	getBranchListCmd.Flags().StringVar(&flagBranchesNewerThan, "newer-than", "", "only branches last committed to within this, such as 90d, 2w or 12h")
+	getBranchListCmd.Flags().BoolVar(&flagBranchesStatus, "status", false, "also count the commits the branches are ahead of and behind the default branch")
+	getBranchCmd.Flags().BoolVar(&flagBranchStatus, "status", false, "also count the commits the branch is ahead of and behind the default branch")
+
+	renameBranchCmd.Flags().BoolVar(&flagRenameRecreateMergeRequests, "recreate-merge-requests", false, "recreate open merge requests from the old branch, closing the originals")
+
+	addBranchCriteriaFlags(pruneStaleBranchesCmd)
+	pruneStaleBranchesCmd.Flags().BoolVarP(&flagPruneYes, "yes", "y", false, "remove the branches without asking for confirmation")
+	pruneStaleBranchesCmd.Flags().BoolVar(&flagPruneDryRun, "dry-run", false, "only show the branches that would be removed")
+
 	projectCmd.AddCommand(getBranchListCmd)
 	projectCmd.AddCommand(getBranchCmd)
+	projectCmd.AddCommand(createBranchCmd)
+	projectCmd.AddCommand(renameBranchCmd)
 	projectCmd.AddCommand(protectCmd)
 	projectCmd.AddCommand(unprotectCmd)
 	projectCmd.AddCommand(pruneStaleBranchesCmd)
@@ -0,0 +1,190 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+type BranchNameOffence struct {
+	Project string
+	Branch  lab.Branch
+}
+
+// branchNamePatterns returns the branch-name-patterns configured in golab-config, of which a branch name must match one.
+func branchNamePatterns() []string {
+	patterns := viper.GetStringSlice("branch-name-patterns")
+	if len(patterns) == 0 {
+		log.Fatal(errors.New("No branch-name-patterns configured"))
+	}
+	return patterns
+}
+
+// branchNameRegex combines the patterns into the single regex used by the branch_name_regex push rule.
+func branchNameRegex(patterns []string) string {
+	if len(patterns) == 1 {
+		return patterns[0]
+	}
+	var groups []string
+	for _, p := range patterns {
+		groups = append(groups, fmt.Sprint("(", p, ")"))
+	}
+	return strings.Join(groups, "|")
+}
+
+// lintBranchNames returns the branches of the project not matching any of the patterns. The default branch is never reported.
+func lintBranchNames(c *gitlab.GitlabClient, p lab.Project, patterns []*regexp.Regexp) ([]BranchNameOffence, error) {
+	var result []BranchNameOffence
+	branches, err := c.Branches(p)
+	if nil != err {
+		return result, err
+	}
+	for _, b := range branches {
+		if b.Default || matchesAny(patterns, b.Name) {
+			continue
+		}
+		result = append(result, BranchNameOffence{Project: p.PathWithNamespace, Branch: b})
+	}
+	return result, nil
+}
+
+func compileBranchNamePatterns(patterns []string) []*regexp.Regexp {
+	var result []*regexp.Regexp
+	for _, p := range patterns {
+		r, err := regexp.Compile(p)
+		if nil != err {
+			log.Fatal(fmt.Sprint("invalid pattern in branch-name-patterns: ", err.Error()))
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// printBranchNameOffences prints the offences, and exits non-zero if there are any.
+func printBranchNameOffences(offences []BranchNameOffence) {
+	if len(offences) == 0 {
+		return
+	}
+	sort.Slice(offences, func(i, j int) bool {
+		if offences[i].Project != offences[j].Project {
+			return offences[i].Project < offences[j].Project
+		}
+		return offences[i].Branch.Name < offences[j].Branch.Name
+	})
+
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Project", "Branch", "Author", "Age")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, o := range offences {
+		tbl.AddRow(o.Project, o.Branch.Name, branchAuthor(o.Branch), formatAge(time.Since(branchCommittedDate(o.Branch))))
+	}
+	tbl.Print()
+	os.Exit(1)
+}
+
+var flagLintSetPushRule bool
+
+// setBranchNamePushRule sets the branch_name_regex push rule of the project to the patterns, unless it already is.
+func setBranchNamePushRule(c *gitlab.GitlabClient, p lab.Project, patterns []string) error {
+	regex := branchNameRegex(patterns)
+	rules, err := c.PushRules(p)
+	if nil != err {
+		return err
+	}
+	if nil != rules && rules.BranchNameRegex == regex {
+		return nil
+	}
+	if err = c.SetPushRules(p, gitlab.PushRuleSettings{BranchNameRegex: &regex}); nil != err {
+		return err
+	}
+	fmt.Println("Push rule branch_name_regex of", p.PathWithNamespace, "set to", regex)
+	return nil
+}
+
+var lintBranchesCmd = &cobra.Command{
+	Use:   "lint-branches",
+	Short: "Report branches of the given project not matching the configured branch-name-patterns [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		patterns := branchNamePatterns()
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		if flagLintSetPushRule {
+			if err = setBranchNamePushRule(c, *p, patterns); nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+		offences, err := lintBranchNames(c, *p, compileBranchNamePatterns(patterns))
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printBranchNameOffences(offences)
+	},
+}
+
+var lintBranchesForNamespaceCmd = &cobra.Command{
+	Use:   "lint-branches-for-namespace",
+	Short: "Report branches of all projects in [namespace] not matching the configured branch-name-patterns",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		patterns := branchNamePatterns()
+		compiled := compileBranchNamePatterns(patterns)
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var offences []BranchNameOffence
+		var projectErrs []ProjectError
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			if flagLintSetPushRule {
+				if err := setBranchNamePushRule(c, p, patterns); nil != err {
+					projectErrs = append(projectErrs, ProjectError{Project: p, Err: err})
+				}
+			}
+			projectOffences, err := lintBranchNames(c, p, compiled)
+			if nil != err {
+				projectErrs = append(projectErrs, ProjectError{Project: p, Err: err})
+				continue
+			}
+			offences = append(offences, projectOffences...)
+		}
+		printProjectErrors(projectErrs)
+		printBranchNameOffences(offences)
+		if len(projectErrs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	lintBranchesCmd.Flags().BoolVar(&flagLintSetPushRule, "set-push-rule", false, "set the branch_name_regex push rule of the project to the configured patterns, if it differs")
+	lintBranchesForNamespaceCmd.Flags().BoolVar(&flagLintSetPushRule, "set-push-rule", false, "set the branch_name_regex push rule of every project to the configured patterns, where it differs")
+
+	projectCmd.AddCommand(lintBranchesCmd)
+	projectCmd.AddCommand(lintBranchesForNamespaceCmd)
+}
@@ -0,0 +1,305 @@
+package project
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// StaleBranch is a single row of a stale branch report.
+type StaleBranch struct {
+	Project          string `json:"project"`
+	Branch           string `json:"branch"`
+	CommitSHA        string `json:"commit_sha"`
+	Author           string `json:"author"`
+	LastCommit       string `json:"last_commit"`
+	AgeDays          int    `json:"age_days"`
+	Merged           bool   `json:"merged"`
+	OpenMergeRequest string `json:"open_merge_request"`
+}
+
+var staleBranchCSVHeader = []string{"project", "branch", "commit_sha", "author", "last_commit", "age_days", "merged", "open_merge_request"}
+
+func newStaleBranch(p lab.Project, s BranchStatus) StaleBranch {
+	sb := StaleBranch{Project: p.PathWithNamespace, Branch: s.Branch.Name, Merged: s.Merged}
+	if cmt := s.Branch.Commit; nil != cmt {
+		sb.CommitSHA = cmt.ID
+		sb.Author = cmt.AuthorName
+		if nil != cmt.CommittedDate {
+			sb.LastCommit = cmt.CommittedDate.Format(time.RFC3339)
+			sb.AgeDays = int(time.Since(*cmt.CommittedDate) / (24 * time.Hour))
+		}
+	}
+	if nil != s.OpenMergeRequest {
+		sb.OpenMergeRequest = s.OpenMergeRequest.WebURL
+	}
+	return sb
+}
+
+func writeStaleBranches(w io.Writer, format string, branches []StaleBranch) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(branches, "", "  ")
+		if nil != err {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(staleBranchCSVHeader)
+		for _, b := range branches {
+			cw.Write([]string{b.Project, b.Branch, b.CommitSHA, b.Author, b.LastCommit, strconv.Itoa(b.AgeDays), strconv.FormatBool(b.Merged), b.OpenMergeRequest})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Project", "Branch", "Author", "Age", "Merged", "Open MR")
+		tbl.WithHeaderFormatter(headerFmt)
+		tbl.WithWriter(w)
+		for _, b := range branches {
+			tbl.AddRow(b.Project, b.Branch, b.Author, fmt.Sprint(b.AgeDays, "d"), b.Merged, b.OpenMergeRequest)
+		}
+		tbl.Print()
+		return nil
+	default:
+		return fmt.Errorf("non valid format %s given", format)
+	}
+}
+
+// readStaleBranches reads a report written as JSON or CSV, based on the file extension.
+func readStaleBranches(path string) ([]StaleBranch, error) {
+	var branches []StaleBranch
+	b, err := ioutil.ReadFile(path)
+	if nil != err {
+		return branches, err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(b, &branches)
+		return branches, err
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if nil != err {
+		return branches, err
+	}
+	if len(records) == 0 {
+		return branches, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"project", "branch", "commit_sha"} {
+		if _, ok := columns[name]; !ok {
+			return branches, fmt.Errorf("%s has no %s column", path, name)
+		}
+	}
+	for _, r := range records[1:] {
+		branches = append(branches, StaleBranch{
+			Project:   r[columns["project"]],
+			Branch:    r[columns["branch"]],
+			CommitSHA: r[columns["commit_sha"]],
+		})
+	}
+	return branches, nil
+}
+
+// deleteStaleBranches removes the branches of the report, unless they got new commits since the report was made. A branch that
+// fails to be looked up or removed is reported without stopping the removal of the others. It returns the number of failures.
+func deleteStaleBranches(c *gitlab.GitlabClient, namespace string, branches []StaleBranch) int {
+	projects := map[string]*lab.Project{}
+	projectErrs := map[string]error{}
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Project", "Branch", "Result")
+	tbl.WithHeaderFormatter(headerFmt)
+	deleted, skipped, failed := 0, 0, 0
+	for _, sb := range branches {
+		result := func() string {
+			p, ok := projects[sb.Project]
+			if !ok {
+				p, projectErrs[sb.Project] = findProjectPath(c, sb.Project)
+				projects[sb.Project] = p
+			}
+			if err := projectErrs[sb.Project]; nil != err {
+				return fmt.Sprint("failed: ", err.Error())
+			}
+			if nil == p.Namespace || p.Namespace.Path != namespace {
+				return fmt.Sprint("skipped: not in namespace ", namespace)
+			}
+			b, err := c.Branch(*p, sb.Branch)
+			if nil != err {
+				return fmt.Sprint("skipped: ", err.Error())
+			}
+			if b.Default {
+				return "skipped: default branch"
+			}
+			if "" != sb.CommitSHA && (nil == b.Commit || b.Commit.ID != sb.CommitSHA) {
+				return "skipped: new commits since the report"
+			}
+			if flagStaleDryRun {
+				return "would be removed"
+			}
+			if err = c.RemoveBranch(*p, *b); nil != err {
+				return fmt.Sprint("failed: ", err.Error())
+			}
+			return "removed"
+		}()
+		switch {
+		case result == "removed" || result == "would be removed":
+			deleted++
+		case strings.HasPrefix(result, "failed: "):
+			failed++
+		default:
+			skipped++
+		}
+		tbl.AddRow(sb.Project, sb.Branch, result)
+	}
+	tbl.Print()
+	if flagStaleDryRun {
+		fmt.Println(deleted, "branches would be removed,", skipped, "skipped,", failed, "failed")
+		return failed
+	}
+	fmt.Println(deleted, "branches removed,", skipped, "skipped,", failed, "failed")
+	return failed
+}
+
+// projectStaleBranches returns the branches of the project that are stale given the criteria.
+func projectStaleBranches(c *gitlab.GitlabClient, p lab.Project, criteria BranchCriteria) ([]StaleBranch, error) {
+	branches, err := c.Branches(p)
+	if nil != err {
+		return nil, err
+	}
+	// The report shows the merged state and open merge request of every branch in it.
+	statuses, reasons, err := staleBranches(c, p, branches, criteria, true)
+	if nil != err {
+		return nil, err
+	}
+	var result []StaleBranch
+	for i, s := range statuses {
+		if "" == reasons[i] {
+			result = append(result, newStaleBranch(p, s))
+		}
+	}
+	return result, nil
+}
+
+var flagStaleFormat string
+var flagStaleOutput string
+var flagStaleDeleteFrom string
+var flagStaleYes bool
+var flagStaleDryRun bool
+
+var staleBranchesCmd = &cobra.Command{
+	Use:   "stale-branches",
+	Short: "Report stale branches of all projects in [namespace], or delete those in a report",
+	Long: `Report stale branches of all projects in a namespace.
+
+Every non-default branch matching the criteria is reported. Write the report as
+CSV or JSON, remove the rows of branches that should be kept, and pass the file
+back with --delete-from to remove the remaining branches. Branches that got new
+commits since the report was made are not removed. Branches that fail to be
+removed are reported without stopping the others, and exit non-zero.
+
+Projects without a repository are skipped. Projects whose branches fail to be
+looked up are listed on stderr after the report, and exit non-zero.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+
+		if "" != flagStaleDeleteFrom {
+			branches, err := readStaleBranches(flagStaleDeleteFrom)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			if !flagStaleDryRun && !confirm(fmt.Sprint("Remove ", len(branches), " branches"), flagStaleYes) {
+				return
+			}
+			if failed := deleteStaleBranches(c, args[0], branches); failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		criteria := branchCriteria()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var active []lab.Project
+		for _, p := range projects {
+			if p.Archived || p.EmptyRepo || p.RepositoryAccessLevel == "disabled" {
+				continue
+			}
+			active = append(active, p)
+		}
+
+		found := make([][]StaleBranch, len(active))
+		projectErrs := fanOutProjects(active, func(i int) error {
+			var err error
+			found[i], err = projectStaleBranches(c, active[i], criteria)
+			return err
+		})
+		result := []StaleBranch{}
+		for _, f := range found {
+			result = append(result, f...)
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Project != result[j].Project {
+				return result[i].Project < result[j].Project
+			}
+			return result[i].Branch < result[j].Branch
+		})
+
+		var w io.Writer = os.Stdout
+		var f *os.File
+		if "" != flagStaleOutput {
+			if f, err = os.Create(flagStaleOutput); nil != err {
+				log.Fatal(err.Error())
+			}
+			w = f
+		}
+		if err = writeStaleBranches(w, flagStaleFormat, result); nil != err {
+			log.Fatal(err.Error())
+		}
+		// The file is closed before exiting, as deferred calls do not run on os.Exit.
+		if nil != f {
+			if err = f.Close(); nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+		printProjectErrors(projectErrs)
+		if len(projectErrs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addBranchCriteriaFlags(staleBranchesCmd)
+	staleBranchesCmd.Flags().StringVarP(&flagStaleFormat, "format", "f", "table", "report format. [table|csv|json]")
+	staleBranchesCmd.Flags().StringVar(&flagStaleOutput, "output", "", "write the report to this file instead of stdout")
+	staleBranchesCmd.Flags().StringVar(&flagStaleDeleteFrom, "delete-from", "", "remove the branches in this CSV or JSON report")
+	staleBranchesCmd.Flags().BoolVarP(&flagStaleYes, "yes", "y", false, "remove the branches without asking for confirmation")
+	staleBranchesCmd.Flags().BoolVar(&flagStaleDryRun, "dry-run", false, "only show the branches that would be removed")
+
+	projectCmd.AddCommand(staleBranchesCmd)
+}
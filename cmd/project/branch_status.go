@@ -0,0 +1,117 @@
+package project
+
+import (
+	"github.com/mvannes/golab/gitlab"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// BranchStatus is a branch together with how it relates to the default branch of its project.
+type BranchStatus struct {
+	Branch lab.Branch
+	Merged bool
+	Ahead  int
+	// Behind is only resolved when asked for, as it takes another comparison.
+	Behind           int
+	OpenMergeRequest *lab.MergeRequest
+	// Resolved tells whether the branch has been compared with the default branch and its open merge request looked up.
+	Resolved bool
+}
+
+// openMergeRequestsBySourceBranch maps the source branches of the open merge requests of the project to their merge request.
+func openMergeRequestsBySourceBranch(c *gitlab.GitlabClient, p lab.Project) (map[string]*lab.MergeRequest, error) {
+	result := map[string]*lab.MergeRequest{}
+	mrs, err := c.ProjectMergeRequests(p, gitlab.Open)
+	if nil != err {
+		return result, err
+	}
+	for _, mr := range mrs {
+		if mr.SourceProjectID == p.ID {
+			result[mr.SourceBranch] = mr
+		}
+	}
+	return result, nil
+}
+
+// mergedIntoDefault tells whether the branch counts as merged, which is when GitLab reports it as merged, or when its head commit
+// is in the default branch without being the head of it. A branch just created from the default branch is not merged, as
+// nothing was merged from it yet.
+func mergedIntoDefault(b lab.Branch, ahead int, defaultHead string) bool {
+	return b.Merged || (ahead == 0 && nil != b.Commit && b.Commit.ID != defaultHead)
+}
+
+// branchStatus compares the branch with the default branch, whose head commit is defaultHead.
+func branchStatus(c *gitlab.GitlabClient, p lab.Project, b lab.Branch, defaultHead string, openMRs map[string]*lab.MergeRequest, withBehind bool) (BranchStatus, error) {
+	status := BranchStatus{Branch: b, Merged: b.Merged, OpenMergeRequest: openMRs[b.Name], Resolved: true}
+	if b.Default || "" == p.DefaultBranch {
+		return status, nil
+	}
+
+	ahead, err := c.Compare(p, p.DefaultBranch, b.Name)
+	if nil != err {
+		return status, err
+	}
+	status.Ahead = len(ahead.Commits)
+	status.Merged = mergedIntoDefault(b, status.Ahead, defaultHead)
+	if withBehind {
+		behind, err := c.Compare(p, b.Name, p.DefaultBranch)
+		if nil != err {
+			return status, err
+		}
+		status.Behind = len(behind.Commits)
+	}
+	return status, nil
+}
+
+// plainBranchStatuses wraps the branches without resolving their status, which takes several requests per branch.
+func plainBranchStatuses(branches []lab.Branch) []BranchStatus {
+	result := make([]BranchStatus, len(branches))
+	for i, b := range branches {
+		result[i] = BranchStatus{Branch: b, Merged: b.Merged}
+	}
+	return result
+}
+
+// branchesWithOpenMergeRequests wraps the branches with their open merge request, without comparing them with the default
+// branch. Unlike the comparisons, the open merge requests of all branches take a single lookup.
+func branchesWithOpenMergeRequests(c *gitlab.GitlabClient, p lab.Project, branches []lab.Branch) ([]BranchStatus, error) {
+	openMRs, err := openMergeRequestsBySourceBranch(c, p)
+	if nil != err {
+		return nil, err
+	}
+	result := plainBranchStatuses(branches)
+	for i := range result {
+		result[i].OpenMergeRequest = openMRs[result[i].Branch.Name]
+	}
+	return result, nil
+}
+
+// branchStatuses resolves the status of all branches concurrently, keeping the order of the branches. Only withBehind are
+// the commits of the default branch that are not in the branch counted.
+func branchStatuses(c *gitlab.GitlabClient, p lab.Project, branches []lab.Branch, withBehind bool) ([]BranchStatus, error) {
+	openMRs, err := openMergeRequestsBySourceBranch(c, p)
+	if nil != err {
+		return nil, err
+	}
+	defaultHead := ""
+	if "" != p.DefaultBranch {
+		defaultBranch, err := c.Branch(p, p.DefaultBranch)
+		if nil != err {
+			return nil, err
+		}
+		if nil != defaultBranch.Commit {
+			defaultHead = defaultBranch.Commit.ID
+		}
+	}
+
+	result := make([]BranchStatus, len(branches))
+	err = fanOut(len(branches), func(i int) error {
+		var err error
+		result[i], err = branchStatus(c, p, branches[i], defaultHead, openMRs, withBehind)
+		return err
+	})
+	if nil != err {
+		return nil, err
+	}
+	return result, nil
+}
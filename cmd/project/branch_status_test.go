@@ -0,0 +1,29 @@
+package project
+
+import (
+	"testing"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+func TestMergedIntoDefault(t *testing.T) {
+	branch := func(sha string, merged bool) lab.Branch {
+		return lab.Branch{Name: "feature", Merged: merged, Commit: &lab.Commit{ID: sha}}
+	}
+	tests := []struct {
+		name     string
+		branch   lab.Branch
+		ahead    int
+		expected bool
+	}{
+		{"reported merged by GitLab", branch("abc", true), 2, true},
+		{"head in the default branch", branch("abc", false), 0, true},
+		{"just created from the default branch", branch("head", false), 0, false},
+		{"commits not in the default branch", branch("abc", false), 1, false},
+	}
+	for _, tt := range tests {
+		if merged := mergedIntoDefault(tt.branch, tt.ahead, "head"); merged != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, merged)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package project
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age      string
+		expected time.Duration
+	}{
+		{"90d", 90 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		age, err := parseAge(tt.age)
+		if nil != err {
+			t.Errorf("parseAge(%q) returned %v", tt.age, err)
+			continue
+		}
+		if age != tt.expected {
+			t.Errorf("parseAge(%q) = %s, expected %s", tt.age, age, tt.expected)
+		}
+	}
+
+	for _, age := range []string{"", "d", "1.5d", "weekd", "ninety", "0d", "-5d", "-12h", "0"} {
+		if _, err := parseAge(age); nil == err {
+			t.Errorf("parseAge(%q) expected an error", age)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+const defaultCIConfigPath = ".gitlab-ci.yml"
+
+// YAML syntax errors mention the position, such as "did not find expected key while parsing a block mapping at line 3 column 1".
+var lintLinePattern = regexp.MustCompile(`at line (\d+)`)
+
+// Messages about configuration refer to the keys, such as "jobs:build:script config should be a string" or "build job: chosen
+// stage does not exist", optionally followed by the unknown keys like "root config contains unknown keys: imagee".
+var lintKeyPattern = regexp.MustCompile(`^(?:jobs:(\S+)|([^\s:]+(?::[^\s:]+)*)(?: config| job:))`)
+var lintUnknownKeysPattern = regexp.MustCompile(`contains unknown keys: ([^\s,]+)`)
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// findKeyLine returns the index of the line defining the key under the parent at index parent, or -1 if there is none.
+// A parent of -1 looks up a key at the top level.
+func findKeyLine(lines []string, parent int, key string) int {
+	start, indent := 0, 0
+	if parent >= 0 {
+		start, indent = parent+1, indentation(lines[parent])+1
+	}
+	for i := start; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if "" == trimmed || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if parent >= 0 && indentation(lines[i]) < indent {
+			return -1
+		}
+		if parent >= 0 || indentation(lines[i]) == 0 {
+			if strings.HasPrefix(trimmed, key+":") || strings.HasPrefix(trimmed, "\""+key+"\":") || strings.HasPrefix(trimmed, "'"+key+"':") {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// ciLintLine returns the line number the lint message is about, or 0 if it cannot be told.
+func ciLintLine(lines []string, message string) int {
+	if m := lintLinePattern.FindStringSubmatch(message); nil != m {
+		line, _ := strconv.Atoi(m[1])
+		return line
+	}
+	m := lintKeyPattern.FindStringSubmatch(message)
+	if nil == m {
+		return 0
+	}
+	keys := strings.Split(m[1]+m[2], ":")
+	if keys[0] == "root" {
+		keys = keys[1:]
+	}
+	if u := lintUnknownKeysPattern.FindStringSubmatch(message); nil != u {
+		keys = append(keys, u[1])
+	}
+
+	found := -1
+	for _, key := range keys {
+		i := findKeyLine(lines, found, key)
+		if i < 0 {
+			break
+		}
+		found = i
+	}
+	return found + 1
+}
+
+// printLineContext prints the line with a line before and after it.
+func printLineContext(lines []string, line int) {
+	for i := line - 1; i <= line+1; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Printf("  %s %4d | %s\n", marker, i, lines[i-1])
+	}
+}
+
+func printLintMessages(label *color.Color, name string, messages []string, lines []string) {
+	for _, message := range messages {
+		fmt.Println(label.Sprint(name), message)
+		if line := ciLintLine(lines, message); line > 0 {
+			printLineContext(lines, line)
+		}
+	}
+}
+
+var flagLintFile string
+var flagLintRef string
+var flagLintMerged bool
+var flagLintSimulate bool
+
+var ciLintCmd = &cobra.Command{
+	Use:   "ci-lint",
+	Short: "Validate a CI configuration in the context of the given project [namespace] [project-name]",
+	Long: `Validate a CI configuration in the context of the given project, so includes of
+the project are resolved.
+
+Validates the local .gitlab-ci.yml, another local file given with --file, or
+with --ref the CI configuration of the project at a branch, tag or commit.
+Errors and warnings are shown with the lines they are about where these can be
+told. Exits non-zero when the configuration is invalid, for use in pre-commit
+hooks.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+
+		var content []byte
+		var err error
+		name := flagLintFile
+		if "" != flagLintRef {
+			name = p.CIConfigPath
+			if "" == name {
+				name = defaultCIConfigPath
+			}
+			// A configuration in another project or at a URL cannot be shown.
+			if !strings.Contains(name, "@") && !strings.Contains(name, "://") {
+				if content, err = c.RawFile(*p, name, flagLintRef); nil != err {
+					log.Fatal(err.Error())
+				}
+			}
+			name = fmt.Sprint(name, "@", flagLintRef)
+		} else if content, err = ioutil.ReadFile(flagLintFile); nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var result *lab.ProjectLintResult
+		if "" != flagLintRef {
+			result, err = c.LintProjectCIConfig(*p, flagLintRef, flagLintSimulate)
+		} else {
+			result, err = c.LintCIConfig(*p, string(content), flagLintSimulate)
+		}
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		if flagLintMerged && "" != result.MergedYaml {
+			fmt.Println(result.MergedYaml)
+		}
+		lines := strings.Split(string(content), "\n")
+		printLintMessages(color.New(color.FgRed, color.Bold), "error:", result.Errors, lines)
+		printLintMessages(color.New(color.FgYellow, color.Bold), "warning:", result.Warnings, lines)
+		if !result.Valid {
+			fmt.Println(name, "is invalid")
+			os.Exit(1)
+		}
+		fmt.Println(name, "is valid")
+	},
+}
+
+func init() {
+	ciLintCmd.Flags().StringVarP(&flagLintFile, "file", "f", defaultCIConfigPath, "local CI configuration file to validate")
+	ciLintCmd.Flags().StringVarP(&flagLintRef, "ref", "r", "", "validate the CI configuration of the project at this branch, tag or commit instead")
+	ciLintCmd.Flags().BoolVarP(&flagLintMerged, "merged", "m", false, "print the configuration with includes expanded")
+	ciLintCmd.Flags().BoolVarP(&flagLintSimulate, "simulate", "s", false, "simulate creating a pipeline, which also validates rules and needs")
+
+	projectCmd.AddCommand(ciLintCmd)
+}
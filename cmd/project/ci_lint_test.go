@@ -0,0 +1,41 @@
+package project
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCIConfig = `stages:
+  - build
+
+image: golang
+
+build:
+  stage: build
+  script:
+    - make
+"quoted":
+  script: make test
+`
+
+func TestCILintLine(t *testing.T) {
+	lines := strings.Split(testCIConfig, "\n")
+	tests := []struct {
+		message  string
+		expected int
+	}{
+		{"(<unknown>): did not find expected key while parsing a block mapping at line 7 column 3", 7},
+		{"jobs:build:script config should be an array of strings", 8},
+		{"jobs:build config contains unknown keys: stage", 7},
+		{"build job: chosen stage does not exist; available stages are .pre, build, .post", 6},
+		{"root config contains unknown keys: image", 4},
+		{"jobs:quoted:script config should be an array of strings", 11},
+		{"jobs:deploy config should implement a script: or a trigger: keyword", 0},
+		{"Included file ci/templates.yml does not exist", 0},
+	}
+	for _, tt := range tests {
+		if line := ciLintLine(lines, tt.message); line != tt.expected {
+			t.Errorf("ciLintLine(%q): expected %d, got %d", tt.message, tt.expected, line)
+		}
+	}
+}
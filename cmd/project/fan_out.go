@@ -0,0 +1,80 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// Maximum number of requests done at the same time when looking up something for many projects, branches or pipelines.
+const requestConcurrency = 10
+
+// fanOut calls f for every index up to n concurrently, with at most requestConcurrency calls at the same time. It returns the
+// error of the lowest index that failed, after all calls are done.
+func fanOut(n int, f func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, requestConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProjectError is a failure to look something up for a single project of a namespace.
+type ProjectError struct {
+	Project lab.Project
+	Err     error
+}
+
+// fanOutProjects calls f for every project concurrently like fanOut, but does not stop at a failing project. It returns the
+// errors of all projects that failed, in the order of the projects.
+func fanOutProjects(projects []lab.Project, f func(i int) error) []ProjectError {
+	errs := make([]error, len(projects))
+	fanOut(len(projects), func(i int) error {
+		errs[i] = f(i)
+		return nil
+	})
+
+	var result []ProjectError
+	for i, err := range errs {
+		if nil != err {
+			result = append(result, ProjectError{Project: projects[i], Err: err})
+		}
+	}
+	return result
+}
+
+// printProjectErrors prints the projects that failed to stderr, so they do not end up in a report written to stdout.
+func printProjectErrors(errs []ProjectError) {
+	if len(errs) == 0 {
+		return
+	}
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Project", "Error")
+	tbl.WithHeaderFormatter(headerFmt)
+	tbl.WithWriter(os.Stderr)
+	for _, e := range errs {
+		tbl.AddRow(e.Project.PathWithNamespace, e.Err.Error())
+	}
+	tbl.Print()
+	fmt.Fprintln(os.Stderr, len(errs), "projects failed")
+}
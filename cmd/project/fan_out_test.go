@@ -0,0 +1,76 @@
+package project
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+func TestFanOut(t *testing.T) {
+	var running, maxRunning int32
+	called := make([]bool, 3*requestConcurrency)
+	err := fanOut(len(called), func(i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		called[i] = true
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i, ok := range called {
+		if !ok {
+			t.Errorf("expected %d to be called", i)
+		}
+	}
+	if maxRunning > requestConcurrency {
+		t.Errorf("expected at most %d calls at the same time, got %d", requestConcurrency, maxRunning)
+	}
+}
+
+func TestFanOutError(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	err := fanOut(5, func(i int) error {
+		switch i {
+		case 1:
+			return first
+		case 3:
+			return second
+		}
+		return nil
+	})
+	if err != first {
+		t.Errorf("expected %v, got %v", first, err)
+	}
+}
+
+func TestFanOutProjects(t *testing.T) {
+	projects := []lab.Project{{ID: 1}, {ID: 2}, {ID: 3}}
+	failed := errors.New("failed")
+	called := make([]bool, len(projects))
+	errs := fanOutProjects(projects, func(i int) error {
+		called[i] = true
+		if projects[i].ID != 2 {
+			return failed
+		}
+		return nil
+	})
+	expected := []ProjectError{{Project: projects[0], Err: failed}, {Project: projects[2], Err: failed}}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %v, got %v", expected, errs)
+	}
+	for i, ok := range called {
+		if !ok {
+			t.Errorf("expected %d to be called", i)
+		}
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
 
+	"github.com/mvannes/golab/cmd/internal/civariable"
 	"github.com/mvannes/golab/gitlab"
 
 	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
 )
 
 var listCmd = &cobra.Command{
@@ -44,9 +49,35 @@ var getCmd = &cobra.Command{
 	},
 }
 
+var flagRevealVariables bool
+
 type ProjectVariable struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Name             string `json:"name"`
+	Value            string `json:"value"`
+	VariableType     string `json:"variable_type"`
+	EnvironmentScope string `json:"environment_scope"`
+	Protected        bool   `json:"protected"`
+	Masked           bool   `json:"masked"`
+}
+
+func newProjectVariable(v *lab.ProjectVariable, reveal bool) ProjectVariable {
+	return ProjectVariable{
+		Name:             v.Key,
+		Value:            civariable.Value(v, reveal),
+		VariableType:     string(v.VariableType),
+		EnvironmentScope: v.EnvironmentScope,
+		Protected:        v.Protected,
+		Masked:           v.Masked,
+	}
+}
+
+// projectVariables leaves out whether the variables are raw, for where only the go-gitlab variables are used.
+func projectVariables(variables []*gitlab.CIVariable) []*lab.ProjectVariable {
+	var result []*lab.ProjectVariable
+	for _, v := range variables {
+		result = append(result, &v.ProjectVariable)
+	}
+	return result
 }
 
 type ProjectVariables struct {
@@ -54,39 +85,89 @@ type ProjectVariables struct {
 	Variables []ProjectVariable `json:"variables"`
 }
 
+type NamespaceVariables struct {
+	Project   lab.Project
+	Variables []*lab.ProjectVariable
+}
+
+// namespaceCIVariables resolves the CI variables of every project in the namespace that has CI enabled.
+func namespaceCIVariables(c *gitlab.GitlabClient, namespace string) ([]NamespaceVariables, error) {
+	projects, err := c.Projects(namespace)
+	if nil != err {
+		return nil, err
+	}
+
+	result := []NamespaceVariables{}
+	for _, p := range projects {
+		if p.Archived == true {
+			continue
+		}
+		if p.BuildsAccessLevel == "disabled" {
+			continue
+		}
+		variables, err := c.CIVariables(p)
+		if nil != err {
+			return nil, err
+		}
+		result = append(result, NamespaceVariables{Project: p, Variables: projectVariables(variables)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project.PathWithNamespace < result[j].Project.PathWithNamespace
+	})
+	return result, nil
+}
+
+var flagQueryKey string
+var flagQueryValue string
+
 var variablesCmd = &cobra.Command{
 	Use:   "ci-variables-for-namespace",
 	Short: "Get project CI variables for all projects in namespace as json",
-	Args:  cobra.ExactArgs(1),
+	Long: `Get project CI variables for all projects in namespace as json.
+
+When a key or value pattern is given, only matching variables and the projects
+defining them are included.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		var keyPattern, valuePattern *regexp.Regexp
+		var err error
+		if "" != flagQueryKey {
+			if keyPattern, err = regexp.Compile(flagQueryKey); nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+		if "" != flagQueryValue {
+			if valuePattern, err = regexp.Compile(flagQueryValue); nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+		query := nil != keyPattern || nil != valuePattern
 
 		c := gitlab.NewClient()
-		projects, err := c.Projects(args[0])
+		namespaceVariables, err := namespaceCIVariables(c, args[0])
 		if nil != err {
 			log.Fatal(err.Error())
 		}
 		projectVariables := []ProjectVariables{}
 
-		for _, p := range projects {
-			if p.Archived == true {
-				continue
+		for _, nv := range namespaceVariables {
+			ciVariables := []ProjectVariable{}
+			for _, v := range nv.Variables {
+				if nil != keyPattern && !keyPattern.MatchString(v.Key) {
+					continue
+				}
+				if nil != valuePattern && !valuePattern.MatchString(v.Value) {
+					continue
+				}
+				ciVariables = append(ciVariables, newProjectVariable(v, flagRevealVariables))
 			}
-			if p.BuildsAccessLevel == "disabled" {
+			if query && len(ciVariables) == 0 {
 				continue
 			}
-			variables, err := c.CIVariables(p)
-			if nil != err {
-				log.Fatal(err.Error())
-			}
-
-			ciVariables := []ProjectVariable{}
-			for _, v := range variables {
-				ciVariables = append(ciVariables, ProjectVariable{Name: v.Key, Value: v.Value})
-			}
 
 			projectVariables = append(
 				projectVariables,
-				ProjectVariables{Project: p.PathWithNamespace, Variables: ciVariables},
+				ProjectVariables{Project: nv.Project.PathWithNamespace, Variables: ciVariables},
 			)
 		}
 
@@ -115,13 +196,16 @@ var variableCmd = &cobra.Command{
 		if nil != err {
 			log.Fatal(err.Error())
 		}
-		for _, v := range variables {
-			fmt.Println(v.Key, v.Value)
-		}
+		civariable.Print(projectVariables(variables), flagRevealVariables)
 	},
 }
 
 func init() {
+	variableCmd.Flags().BoolVar(&flagRevealVariables, "reveal", false, "print variable values instead of redacting them")
+	variablesCmd.Flags().BoolVar(&flagRevealVariables, "reveal", false, "print variable values instead of redacting them")
+	variablesCmd.Flags().StringVarP(&flagQueryKey, "key", "k", "", "only include variables with a key matching this regex")
+	variablesCmd.Flags().StringVarP(&flagQueryValue, "value", "v", "", "only include variables with a value matching this regex")
+
 	projectCmd.AddCommand(getCmd)
 	projectCmd.AddCommand(listCmd)
 	projectCmd.AddCommand(variableCmd)
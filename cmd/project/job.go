@@ -0,0 +1,112 @@
+package project
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+var flagJobPipeline string
+
+// resolveJob resolves the name of a job in the pipeline given with --pipeline, or a job ID. Without --pipeline the latest
+// pipeline of the default branch is used. A number is only taken as a job ID when no job of the pipeline is named like it.
+func resolveJob(c *gitlab.GitlabClient, p lab.Project, nameOrID string) *lab.Job {
+	id, idErr := strconv.Atoi(nameOrID)
+	refOrID := flagJobPipeline
+	if "" == refOrID {
+		refOrID = p.DefaultBranch
+	}
+	pipelineID, err := findPipelineID(c, p, refOrID)
+	if nil != err && nil != idErr {
+		log.Fatal(err.Error())
+	}
+
+	if nil == err {
+		jobs, err := c.JobsForPipeline(p, lab.PipelineInfo{ID: pipelineID})
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		for _, j := range jobs {
+			if j.Name == nameOrID {
+				return j
+			}
+		}
+	}
+	if nil == idErr {
+		job, err := c.Job(p, id)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		return job
+	}
+	log.Fatal(fmt.Errorf("No job %s found in pipeline %d", nameOrID, pipelineID))
+	return nil
+}
+
+func printJob(j *lab.Job) {
+	fmt.Printf("Job #%d %s: %s %s\n", j.ID, j.Name, j.Status, j.WebURL)
+}
+
+// runJobAction resolves the job of the arguments, and prints the job returned by the action.
+func runJobAction(args []string, action func(c *gitlab.GitlabClient, p lab.Project, id int) (*lab.Job, error)) {
+	c := gitlab.NewClient()
+	p := requireProject(c, args[0], args[1])
+	job := resolveJob(c, *p, args[2])
+	result, err := action(c, *p, job.ID)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	printJob(result)
+}
+
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Manage the jobs of a project",
+	Long: `Manage the jobs of a project.
+
+A job is given by its name in the pipeline given with --pipeline, which
+defaults to the latest pipeline of the default branch, or by its ID. A number
+is taken as a job name first, so a job named like a number is not mistaken
+for a job ID.`,
+}
+
+var retryJobCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry a job of the given project [namespace] [project-name] [job-name|job-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		runJobAction(args, (*gitlab.GitlabClient).RetryJob)
+	},
+}
+
+var cancelJobCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel a job of the given project [namespace] [project-name] [job-name|job-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		runJobAction(args, (*gitlab.GitlabClient).CancelJob)
+	},
+}
+
+var playJobCmd = &cobra.Command{
+	Use:   "play",
+	Short: "Start a manual job of the given project [namespace] [project-name] [job-name|job-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		runJobAction(args, (*gitlab.GitlabClient).PlayJob)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{retryJobCmd, cancelJobCmd, playJobCmd} {
+		cmd.Flags().StringVarP(&flagJobPipeline, "pipeline", "p", "", "branch, tag or pipeline ID to find the job by name in, defaults to the default branch")
+		jobCmd.AddCommand(cmd)
+	}
+
+	projectCmd.AddCommand(jobCmd)
+}
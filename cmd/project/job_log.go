@@ -0,0 +1,215 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// GitLab marks the sections of a job log, such as the job script, with lines like section_start:1650000000:step_script
+// optionally followed by options in brackets.
+var sectionPattern = regexp.MustCompile(`section_(start|end):\d+:([A-Za-z0-9_.-]+)(\[[^\]]*\])?\r?`)
+
+// stripTrace removes the color codes and section markers of a log line, and what a carriage return would overwrite.
+func stripTrace(line string) string {
+	line = sectionPattern.ReplaceAllString(line, "")
+	line = ansiPattern.ReplaceAllString(line, "")
+	line = strings.TrimRight(line, "\r")
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		line = line[i+1:]
+	}
+	return line
+}
+
+// traceRenderer renders a job log line by line, keeping track of the sections it is in.
+type traceRenderer struct {
+	Strip    bool
+	Collapse bool
+	// Expand holds the globs of the sections whose content is shown when collapsing.
+	Expand []*regexp.Regexp
+	// hidden is the name of the collapsed section being skipped.
+	hidden string
+}
+
+// render returns the line to print, and false if the line is hidden.
+func (r *traceRenderer) render(line string) (string, bool) {
+	if r.Collapse {
+		for _, m := range sectionPattern.FindAllStringSubmatch(line, -1) {
+			if "" == r.hidden && m[1] == "start" {
+				if !matchesAnyGlob(r.Expand, m[2]) {
+					r.hidden = m[2]
+				}
+				return fmt.Sprint("> ", stripTrace(line)), true
+			}
+			if m[1] == "end" && m[2] == r.hidden {
+				r.hidden = ""
+				line = line[strings.Index(line, m[0])+len(m[0]):]
+				if "" == stripTrace(line) {
+					return "", false
+				}
+			}
+		}
+		if "" != r.hidden {
+			return "", false
+		}
+	}
+	if r.Strip {
+		stripped := stripTrace(line)
+		// Lines only marking the end of a section are empty without the marker.
+		return stripped, "" != stripped || !sectionPattern.MatchString(line)
+	}
+	return line, true
+}
+
+func printTrace(r *traceRenderer, trace string) {
+	for _, line := range strings.Split(trace, "\n") {
+		if rendered, ok := r.render(line); ok {
+			fmt.Println(rendered)
+		}
+	}
+}
+
+// followTrace prints the log of the job as it is written, until the job is finished.
+func followTrace(c *gitlab.GitlabClient, p lab.Project, job *lab.Job, r *traceRenderer, interval time.Duration) {
+	printed := 0
+	for {
+		finished := finishedPipelineStatuses[job.Status]
+		trace, err := c.JobTrace(p, job.ID)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if len(trace) > printed {
+			pending := string(trace[printed:])
+			// Only complete lines are printed, unless nothing will be added anymore.
+			if i := strings.LastIndex(pending, "\n"); i >= 0 && !finished {
+				pending = pending[:i]
+			} else if !finished {
+				pending = ""
+			}
+			if "" != pending {
+				printTrace(r, strings.TrimSuffix(pending, "\n"))
+				printed += len(pending) + 1
+			}
+		}
+		if finished {
+			return
+		}
+		time.Sleep(interval)
+		job, err = c.Job(p, job.ID)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+	}
+}
+
+// grepFailedJobs prints the lines of the logs of the failed jobs in the pipeline matching the pattern, and returns whether any matched.
+func grepFailedJobs(c *gitlab.GitlabClient, p lab.Project, pipelineID int, pattern *regexp.Regexp) bool {
+	jobs, err := c.JobsForPipeline(p, lab.PipelineInfo{ID: pipelineID})
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	matched := false
+	for _, j := range jobs {
+		if j.Status != "failed" {
+			continue
+		}
+		trace, err := c.JobTrace(p, j.ID)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		for i, line := range strings.Split(string(trace), "\n") {
+			line = stripTrace(line)
+			if pattern.MatchString(line) {
+				matched = true
+				fmt.Printf("%s:%d: %s\n", color.New(color.FgMagenta).Sprint(j.Name), i+1, line)
+			}
+		}
+	}
+	return matched
+}
+
+var flagLogFollow bool
+var flagLogInterval time.Duration
+var flagLogStrip bool
+var flagLogCollapse bool
+var flagLogExpand []string
+var flagLogGrep string
+
+var jobLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Print the log of a job of the given project [namespace] [project-name] [job-name|job-id]",
+	Long: `Print the log of a job of the given project.
+
+With --collapse only the header of every section of the log is shown, except
+for the sections given with --expand, which defaults to the job script.
+
+With --grep the logs of all failed jobs in the pipeline given with --pipeline
+are searched instead, in which case the job is left out of the arguments.
+Exits non-zero when nothing matched.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+
+		if "" != flagLogGrep {
+			if len(args) != 2 {
+				log.Fatal(errors.New("With --grep only [namespace] [project-name] are given, use --pipeline to select the pipeline"))
+			}
+			pattern, err := regexp.Compile(flagLogGrep)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			refOrID := flagJobPipeline
+			if "" == refOrID {
+				refOrID = p.DefaultBranch
+			}
+			if !grepFailedJobs(c, *p, resolvePipelineID(c, *p, refOrID), pattern) {
+				os.Exit(1)
+			}
+			return
+		}
+		if len(args) != 3 {
+			log.Fatal(errors.New("No job given"))
+		}
+
+		expand, err := compileGlobs(flagLogExpand)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		job := resolveJob(c, *p, args[2])
+		r := &traceRenderer{Strip: flagLogStrip, Collapse: flagLogCollapse, Expand: expand}
+		if flagLogFollow {
+			followTrace(c, *p, job, r, flagLogInterval)
+			return
+		}
+		trace, err := c.JobTrace(*p, job.ID)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printTrace(r, strings.TrimSuffix(string(trace), "\n"))
+	},
+}
+
+func init() {
+	jobLogCmd.Flags().StringVarP(&flagJobPipeline, "pipeline", "p", "", "branch, tag or pipeline ID to find the job by name in, defaults to the default branch")
+	jobLogCmd.Flags().BoolVarP(&flagLogFollow, "follow", "f", false, "keep printing the log as it is written until the job is finished")
+	jobLogCmd.Flags().DurationVarP(&flagLogInterval, "interval", "i", 2*time.Second, "time between polls when following")
+	jobLogCmd.Flags().BoolVarP(&flagLogStrip, "strip", "s", false, "remove colors and section markers")
+	jobLogCmd.Flags().BoolVarP(&flagLogCollapse, "collapse", "c", false, "only show the headers of sections")
+	jobLogCmd.Flags().StringSliceVarP(&flagLogExpand, "expand", "e", []string{"step_script"}, "sections to show the content of when collapsing, globs are allowed")
+	jobLogCmd.Flags().StringVarP(&flagLogGrep, "grep", "g", "", "search the logs of the failed jobs in the pipeline for this regex")
+
+	jobCmd.AddCommand(jobLogCmd)
+}
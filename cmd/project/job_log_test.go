@@ -0,0 +1,104 @@
+package project
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStripTrace(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"\x1b[32;1mgreen\x1b[0;m", "green"},
+		{"\x1b[0Ksection_start:1650000000:step_script\r\x1b[0K\x1b[32;1mExecuting step\x1b[0;m", "Executing step"},
+		{"\x1b[0Ksection_start:1650000000:prepare[collapsed=true]\r\x1b[0KPreparing", "Preparing"},
+		{"\x1b[0Ksection_end:1650000001:step_script\r\x1b[0K", ""},
+		{"Downloading 10%\rDownloading 50%\rDownloading 100%", "Downloading 100%"},
+		{"windows line\r", "windows line"},
+	}
+	for _, tt := range tests {
+		if stripped := stripTrace(tt.line); stripped != tt.expected {
+			t.Errorf("stripTrace(%q): expected %q, got %q", tt.line, tt.expected, stripped)
+		}
+	}
+}
+
+const testTrace = "Running with gitlab-runner\n" +
+	"\x1b[0Ksection_start:1650000000:prepare_script\r\x1b[0KPreparing environment\n" +
+	"Running on runner-1\n" +
+	"\x1b[0Ksection_end:1650000001:prepare_script\r\x1b[0K\n" +
+	"\x1b[0Ksection_start:1650000002:step_script\r\x1b[0KExecuting step_script\n" +
+	"\x1b[32;1m$ make test\x1b[0;m\n" +
+	"ok\n" +
+	"\x1b[0Ksection_end:1650000003:step_script\r\x1b[0K\x1b[32;1mJob succeeded\x1b[0;m"
+
+func renderTrace(r *traceRenderer, trace string) []string {
+	var result []string
+	for _, line := range strings.Split(trace, "\n") {
+		if rendered, ok := r.render(line); ok {
+			result = append(result, rendered)
+		}
+	}
+	return result
+}
+
+func TestTraceRenderer(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer traceRenderer
+		expected []string
+	}{
+		{
+			name:     "as is",
+			renderer: traceRenderer{},
+			expected: strings.Split(testTrace, "\n"),
+		},
+		{
+			name:     "strip",
+			renderer: traceRenderer{Strip: true},
+			expected: []string{
+				"Running with gitlab-runner",
+				"Preparing environment",
+				"Running on runner-1",
+				"Executing step_script",
+				"$ make test",
+				"ok",
+				"Job succeeded",
+			},
+		},
+		{
+			name:     "collapse",
+			renderer: traceRenderer{Strip: true, Collapse: true},
+			expected: []string{
+				"Running with gitlab-runner",
+				"> Preparing environment",
+				"> Executing step_script",
+				"Job succeeded",
+			},
+		},
+		{
+			name:     "collapse with expanded section",
+			renderer: traceRenderer{Strip: true, Collapse: true, Expand: []*regexp.Regexp{regexp.MustCompile(`^step_.*$`)}},
+			expected: []string{
+				"Running with gitlab-runner",
+				"> Preparing environment",
+				"> Executing step_script",
+				"$ make test",
+				"ok",
+				"Job succeeded",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.renderer
+			if rendered := renderTrace(&r, testTrace); !reflect.DeepEqual(rendered, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, rendered)
+			}
+		})
+	}
+}
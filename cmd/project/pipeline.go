@@ -2,51 +2,224 @@ package project
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/mvannes/golab/gitlab"
+
+	lab "github.com/xanzy/go-gitlab"
 )
 
+// Pipeline statuses that will not change anymore without someone acting on the pipeline.
+var finishedPipelineStatuses = map[string]bool{
+	"success":  true,
+	"failed":   true,
+	"canceled": true,
+	"skipped":  true,
+	"manual":   true,
+}
+
+// errPipelineTimeout is returned by waitForPipeline when the pipeline did not finish within the timeout.
+var errPipelineTimeout = errors.New("timed out")
+
+// waitForPipeline polls the pipeline until it is finished, or until the timeout passes if it is not zero, which returns an error
+// wrapping errPipelineTimeout. When given, onPoll is called with the pipeline after every poll.
+func waitForPipeline(c *gitlab.GitlabClient, p lab.Project, id int, interval, timeout time.Duration, onPoll func(*lab.Pipeline) error) (*lab.Pipeline, error) {
+	start := time.Now()
+	for {
+		pipeline, err := c.Pipeline(p, id)
+		if nil != err {
+			return nil, err
+		}
+		if nil != onPoll {
+			if err = onPoll(pipeline); nil != err {
+				return pipeline, err
+			}
+		}
+		if finishedPipelineStatuses[pipeline.Status] {
+			return pipeline, nil
+		}
+		if 0 != timeout && time.Since(start) > timeout {
+			return pipeline, fmt.Errorf("%w: pipeline %d still %s after %s", errPipelineTimeout, id, pipeline.Status, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// latestPipeline returns the most recent pipeline matching the filter, and an error if there is none.
+func latestPipeline(c *gitlab.GitlabClient, p lab.Project, f gitlab.PipelineFilter) (*lab.PipelineInfo, error) {
+	pipeline, err := c.LatestPipeline(p, f)
+	if nil != err {
+		return nil, err
+	}
+	if nil == pipeline && "" != f.Ref {
+		return nil, fmt.Errorf("No pipeline found for %s", f.Ref)
+	}
+	if nil == pipeline {
+		return nil, errors.New("No pipeline found")
+	}
+	return pipeline, nil
+}
+
+// findPipelineID finds the latest pipeline of a branch or tag, or a pipeline ID. A number is only taken as a pipeline ID when
+// no branch or tag named like it has pipelines, so a tag such as 2024 is not mistaken for a pipeline ID.
+func findPipelineID(c *gitlab.GitlabClient, p lab.Project, refOrID string) (int, error) {
+	pipeline, err := c.LatestPipeline(p, gitlab.PipelineFilter{Ref: refOrID})
+	if nil != err {
+		return 0, err
+	}
+	if nil != pipeline {
+		return pipeline.ID, nil
+	}
+	if id, err := strconv.Atoi(refOrID); nil == err {
+		return id, nil
+	}
+	return 0, fmt.Errorf("No pipeline found for %s", refOrID)
+}
+
+// resolvePipelineID resolves a pipeline ID like findPipelineID, exiting when it cannot be found.
+func resolvePipelineID(c *gitlab.GitlabClient, p lab.Project, refOrID string) int {
+	id, err := findPipelineID(c, p, refOrID)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	return id
+}
+
 type JobInfo struct {
-	Name         string `json:"name"`
-	Status       string `json:"status"`
-	AllowFailure bool   `json:"allow_failure"`
+	ID             int     `json:"id"`
+	Name           string  `json:"name"`
+	Stage          string  `json:"stage"`
+	Status         string  `json:"status"`
+	AllowFailure   bool    `json:"allow_failure"`
+	Duration       float64 `json:"duration"`
+	QueuedDuration float64 `json:"queued_duration"`
+	Runner         string  `json:"runner,omitempty"`
+	WebURL         string  `json:"web_url"`
+	FailureReason  string  `json:"failure_reason,omitempty"`
+	// Downstream is the pipeline triggered by a bridge job.
+	Downstream *DownstreamPipelineInfo `json:"downstream,omitempty"`
+}
+
+type DownstreamPipelineInfo struct {
+	ID        int       `json:"id"`
+	ProjectID int       `json:"project_id"`
+	Status    string    `json:"status"`
+	WebURL    string    `json:"web_url"`
+	Jobs      []JobInfo `json:"jobs"`
 }
 
+// pipelineJobInfos returns the jobs of the pipeline. With downstream the bridge jobs are included as well, with the jobs of
+// the pipelines they triggered.
+func pipelineJobInfos(c *gitlab.GitlabClient, p lab.Project, pipeline lab.PipelineInfo, downstream bool) ([]JobInfo, error) {
+	jobs, err := c.PipelineJobs(p, pipeline)
+	if nil != err {
+		return nil, err
+	}
+	result := []JobInfo{}
+	for _, j := range jobs {
+		result = append(result, JobInfo{
+			ID:             j.ID,
+			Name:           j.Name,
+			Stage:          j.Stage,
+			Status:         j.Status,
+			AllowFailure:   j.AllowFailure,
+			Duration:       j.Duration,
+			QueuedDuration: j.QueuedDuration,
+			Runner:         j.Runner.Description,
+			WebURL:         j.WebURL,
+			FailureReason:  j.FailureReason,
+		})
+	}
+	if !downstream {
+		return result, nil
+	}
+
+	bridges, err := c.PipelineBridges(p, pipeline)
+	if nil != err {
+		return nil, err
+	}
+	for _, b := range bridges {
+		info := JobInfo{
+			ID:           b.ID,
+			Name:         b.Name,
+			Stage:        b.Stage,
+			Status:       b.Status,
+			AllowFailure: b.AllowFailure,
+			Duration:     b.Duration,
+			WebURL:       b.WebURL,
+		}
+		// Bridges that did not trigger yet have no downstream pipeline.
+		if nil != b.DownstreamPipeline {
+			// Multi-project pipelines run in another project.
+			downstreamJobs, err := pipelineJobInfos(c, lab.Project{ID: b.DownstreamPipeline.ProjectID}, *b.DownstreamPipeline, true)
+			if nil != err {
+				return nil, err
+			}
+			info.Downstream = &DownstreamPipelineInfo{
+				ID:        b.DownstreamPipeline.ID,
+				ProjectID: b.DownstreamPipeline.ProjectID,
+				Status:    b.DownstreamPipeline.Status,
+				WebURL:    b.DownstreamPipeline.WebURL,
+				Jobs:      downstreamJobs,
+			}
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+var flagJobsPipeline int
+var flagJobsSHA string
+var flagJobsStatus string
+var flagJobsSource string
+var flagJobsDownstream bool
+
 var jobsInBranchCmd = &cobra.Command{
 	Use:   "jobs-in-branch",
 	Short: "List all for the latest pipeline in a project by [namespace], [name] and [branch-or-tag]",
-	Args:  cobra.ExactArgs(3),
+	Long: `List the jobs of the latest pipeline for a branch or tag as JSON.
+
+The pipeline is narrowed down with --sha, --status and --source, such as push,
+merge_request_event or schedule, in which case the branch or tag may be left
+out. With --pipeline the pipeline with that ID is used instead, which cannot be
+combined with a branch or tag or the other flags narrowing down the pipeline.
+With --downstream the bridge jobs are listed as well, with the jobs of the
+child or multi-project pipelines they triggered.`,
+	Args: cobra.RangeArgs(2, 3),
 	Run: func(cmd *cobra.Command, args []string) {
 		c := gitlab.NewClient()
-		p, err := c.Project(args[0], args[1])
-		if nil != err {
-			log.Fatal(err.Error())
-		}
-		pipelines, err := c.Pipelines(*p, args[2])
-		if nil != err {
-			log.Fatal(err.Error())
+		p := requireProject(c, args[0], args[1])
+
+		filter := gitlab.PipelineFilter{SHA: flagJobsSHA, Status: flagJobsStatus, Source: flagJobsSource}
+		if len(args) == 3 {
+			filter.Ref = args[2]
 		}
-		if len(pipelines) == 0 {
-			log.Fatal("No pipeline found to resolve jobs for.")
+		var pipeline *lab.PipelineInfo
+		if cmd.Flag("pipeline").Changed {
+			if filter != (gitlab.PipelineFilter{}) {
+				log.Fatal(errors.New("--pipeline cannot be combined with a branch or tag, --sha, --status or --source"))
+			}
+			pipeline = &lab.PipelineInfo{ID: flagJobsPipeline}
+		} else {
+			if filter == (gitlab.PipelineFilter{}) {
+				log.Fatal(errors.New("No branch or tag given"))
+			}
+			var err error
+			if pipeline, err = latestPipeline(c, *p, filter); nil != err {
+				log.Fatal(err.Error())
+			}
 		}
-		pipeline := pipelines[0]
-		jobs, err := c.JobsForPipeline(*p, *pipeline)
+
+		result, err := pipelineJobInfos(c, *p, *pipeline, flagJobsDownstream)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		result := []JobInfo{}
-		for _, j := range jobs {
-			result = append(result, JobInfo{
-				Name:         j.Name,
-				Status:       j.Status,
-				AllowFailure: j.AllowFailure,
-			})
-		}
-
 		b, err := json.Marshal(result)
 		if err != nil {
 			log.Fatal(err)
@@ -55,6 +228,23 @@ var jobsInBranchCmd = &cobra.Command{
 	},
 }
 
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Manage the pipelines of a project",
+	Long: `Manage the pipelines of a project.
+
+A pipeline is given by a branch or tag, of which the latest pipeline is used,
+or by its ID. A number is taken as a branch or tag first, so a tag such as 2024
+is not mistaken for a pipeline ID.`,
+}
+
 func init() {
+	jobsInBranchCmd.Flags().IntVarP(&flagJobsPipeline, "pipeline", "p", 0, "ID of the pipeline to list the jobs of, instead of the latest pipeline for the branch or tag")
+	jobsInBranchCmd.Flags().StringVar(&flagJobsSHA, "sha", "", "only use pipelines for this commit")
+	jobsInBranchCmd.Flags().StringVar(&flagJobsStatus, "status", "", "only use pipelines with this status, such as success or failed")
+	jobsInBranchCmd.Flags().StringVar(&flagJobsSource, "source", "", "only use pipelines triggered by this source, such as push, merge_request_event or schedule")
+	jobsInBranchCmd.Flags().BoolVarP(&flagJobsDownstream, "downstream", "d", false, "include bridge jobs and the jobs of the pipelines they triggered")
+
 	projectCmd.AddCommand(jobsInBranchCmd)
+	projectCmd.AddCommand(pipelineCmd)
 }
@@ -0,0 +1,81 @@
+package project
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+func printPipeline(pipeline *lab.Pipeline) {
+	fmt.Printf("Pipeline #%d on %s: %s %s\n", pipeline.ID, pipeline.Ref, pipeline.Status, pipeline.WebURL)
+}
+
+var pipelineRetryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Retry the failed jobs of a pipeline [namespace] [project-name] [branch-or-tag|pipeline-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		pipeline, err := c.RetryPipeline(*p, resolvePipelineID(c, *p, args[2]))
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printPipeline(pipeline)
+	},
+}
+
+var pipelineCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel the running jobs of a pipeline [namespace] [project-name] [branch-or-tag|pipeline-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		pipeline, err := c.CancelPipeline(*p, resolvePipelineID(c, *p, args[2]))
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		printPipeline(pipeline)
+	},
+}
+
+var pipelinePlayCmd = &cobra.Command{
+	Use:   "play",
+	Short: "Start all manual jobs of a pipeline [namespace] [project-name] [branch-or-tag|pipeline-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		jobs, err := c.JobsForPipeline(*p, lab.PipelineInfo{ID: resolvePipelineID(c, *p, args[2])})
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		played := 0
+		for _, j := range jobs {
+			if j.Status != "manual" {
+				continue
+			}
+			job, err := c.PlayJob(*p, j.ID)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			printJob(job)
+			played++
+		}
+		if played == 0 {
+			fmt.Println("No manual jobs")
+		}
+	},
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineRetryCmd)
+	pipelineCmd.AddCommand(pipelineCancelCmd)
+	pipelineCmd.AddCommand(pipelinePlayCmd)
+}
@@ -0,0 +1,113 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// pipelineVariables combines the variables of the file with those given as KEY=VALUE, the latter taking precedence.
+func pipelineVariables(file string, vars []string) ([]*lab.PipelineVariable, error) {
+	byKey := map[string]*lab.PipelineVariable{}
+	if "" != file {
+		fileVariables, err := readVariablesFile(file, "*")
+		if nil != err {
+			return nil, err
+		}
+		for _, v := range fileVariables {
+			byKey[v.Key] = &lab.PipelineVariable{Key: v.Key, Value: v.Value, VariableType: v.VariableType}
+		}
+	}
+	for _, v := range vars {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || "" == parts[0] {
+			return nil, fmt.Errorf("Variable %s is not of the form KEY=VALUE", v)
+		}
+		byKey[parts[0]] = &lab.PipelineVariable{Key: parts[0], Value: parts[1]}
+	}
+
+	var result []*lab.PipelineVariable
+	for _, v := range byKey {
+		if "" == v.VariableType {
+			v.VariableType = "env_var"
+		}
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result, nil
+}
+
+var flagRunVars []string
+var flagRunVarFile string
+var flagRunTriggerToken string
+var flagRunWatch bool
+
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run a pipeline for a branch or tag of the given project [namespace] [project-name] [branch-or-tag]",
+	Long: `Run a pipeline for a branch or tag of the given project.
+
+Variables are given with --var KEY=VALUE, or read from a YAML or dotenv file
+with --var-file as used by ci-variables sync. With --trigger-token the pipeline
+is started through a pipeline trigger, which does not support file variables.
+With --watch the pipeline is watched until it finishes, exiting as pipeline
+watch does.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		variables, err := pipelineVariables(flagRunVarFile, flagRunVars)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		var pipeline *lab.Pipeline
+		if "" != flagRunTriggerToken {
+			triggerVariables := map[string]string{}
+			for _, v := range variables {
+				if v.VariableType != "env_var" {
+					log.Fatal(fmt.Errorf("Variable %s is of type %s, which a trigger cannot pass", v.Key, v.VariableType))
+				}
+				triggerVariables[v.Key] = v.Value
+			}
+			pipeline, err = c.TriggerPipeline(*p, args[2], flagRunTriggerToken, triggerVariables)
+		} else {
+			pipeline, err = c.CreatePipeline(*p, args[2], variables)
+		}
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("Pipeline #%d created: %s\n", pipeline.ID, pipeline.WebURL)
+
+		if flagRunWatch {
+			os.Exit(watchPipeline(c, *p, pipeline.ID, flagWatchInterval, flagWatchTimeout))
+		}
+	},
+}
+
+func init() {
+	pipelineRunCmd.Flags().StringArrayVarP(&flagRunVars, "var", "v", nil, "pipeline variable as KEY=VALUE, can be given multiple times")
+	pipelineRunCmd.Flags().StringVarP(&flagRunVarFile, "var-file", "f", "", "YAML or dotenv file with pipeline variables")
+	pipelineRunCmd.Flags().StringVarP(&flagRunTriggerToken, "trigger-token", "t", "", "run the pipeline through the pipeline trigger with this token")
+	pipelineRunCmd.Flags().BoolVarP(&flagRunWatch, "watch", "w", false, "watch the pipeline until it finishes")
+	pipelineRunCmd.Flags().DurationVarP(&flagWatchInterval, "interval", "i", 5*time.Second, "time between polls when watching")
+	pipelineRunCmd.Flags().DurationVar(&flagWatchTimeout, "timeout", 0, "maximum time to wait for the pipeline when watching, 0 to wait forever")
+
+	pipelineCmd.AddCommand(pipelineRunCmd)
+}
@@ -0,0 +1,431 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/cmd/internal/civariable"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// requireSchedule returns the schedule with the given ID including its variables, and exits when there is none.
+func requireSchedule(c *gitlab.GitlabClient, p lab.Project, id string) *lab.PipelineSchedule {
+	scheduleID, err := strconv.Atoi(id)
+	if nil != err {
+		log.Fatal(fmt.Errorf("Schedule ID %s is not a number", id))
+	}
+	schedule, err := c.PipelineSchedule(p, scheduleID)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	if nil == schedule {
+		log.Fatal(fmt.Errorf("No schedule %d found", scheduleID))
+	}
+	return schedule
+}
+
+func scheduleOwner(s *lab.PipelineSchedule) string {
+	if nil == s.Owner {
+		return ""
+	}
+	return s.Owner.Username
+}
+
+func scheduleNextRun(s *lab.PipelineSchedule) string {
+	if nil == s.NextRunAt || !s.Active {
+		return ""
+	}
+	return s.NextRunAt.Format(time.RFC3339)
+}
+
+func printSchedule(s *lab.PipelineSchedule, reveal bool) {
+	fmt.Printf("Schedule #%d %s\n", s.ID, s.Description)
+	fmt.Println("Ref:", s.Ref)
+	fmt.Println("Cron:", s.Cron, s.CronTimezone)
+	fmt.Println("Active:", s.Active)
+	fmt.Println("Owner:", scheduleOwner(s))
+	fmt.Println("Next run:", scheduleNextRun(s))
+	if s.LastPipeline.ID != 0 {
+		fmt.Printf("Last pipeline: #%d %s\n", s.LastPipeline.ID, s.LastPipeline.Status)
+	}
+	if len(s.Variables) == 0 {
+		return
+	}
+
+	fmt.Println()
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Key", "Value", "Type")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, v := range s.Variables {
+		value := civariable.Redacted
+		if reveal {
+			value = v.Value
+		}
+		tbl.AddRow(v.Key, value, v.VariableType)
+	}
+	tbl.Print()
+}
+
+// setScheduleVariables sets the variables on the schedule and removes the given keys from it.
+func setScheduleVariables(c *gitlab.GitlabClient, p lab.Project, schedule lab.PipelineSchedule, variables []*lab.PipelineVariable, remove []string) error {
+	for _, v := range variables {
+		if err := c.SetPipelineScheduleVariable(p, schedule, *v); nil != err {
+			return err
+		}
+	}
+	for _, key := range remove {
+		if err := c.RemovePipelineScheduleVariable(p, schedule, key); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScheduleReport is a pipeline schedule of a project and the problems found with it.
+type ScheduleReport struct {
+	Project  lab.Project
+	Schedule *lab.PipelineSchedule
+	Problems []string
+}
+
+// ownerDeparted returns whether the owner of the schedule can no longer run its pipelines, because the account is no
+// longer active or is no longer a member of the project.
+func ownerDeparted(c *gitlab.GitlabClient, p lab.Project, s *lab.PipelineSchedule) (bool, error) {
+	if nil == s.Owner {
+		return true, nil
+	}
+	if s.Owner.State != "active" {
+		return true, nil
+	}
+	member, err := c.IsProjectMember(p, s.Owner.ID)
+	return !member, err
+}
+
+func projectScheduleReports(c *gitlab.GitlabClient, p lab.Project) ([]ScheduleReport, error) {
+	schedules, err := c.PipelineSchedules(p)
+	if nil != err {
+		return nil, err
+	}
+	var result []ScheduleReport
+	for _, s := range schedules {
+		// Only a single schedule includes its last pipeline.
+		schedule, err := c.PipelineSchedule(p, s.ID)
+		if nil != err {
+			return nil, err
+		}
+		if nil == schedule {
+			continue
+		}
+		report := ScheduleReport{Project: p, Schedule: schedule}
+		if !schedule.Active {
+			report.Problems = append(report.Problems, "inactive")
+		}
+		departed, err := ownerDeparted(c, p, schedule)
+		if nil != err {
+			return nil, err
+		}
+		if departed {
+			report.Problems = append(report.Problems, "owner departed")
+		}
+		if schedule.LastPipeline.Status == "failed" {
+			report.Problems = append(report.Problems, "last run failed")
+		}
+		result = append(result, report)
+	}
+	return result, nil
+}
+
+// scheduleReports reports the schedules of all projects concurrently, sorted by project path and schedule ID. Projects whose
+// schedules fail to be looked up are left out of the reports, and returned with their error.
+func scheduleReports(c *gitlab.GitlabClient, projects []lab.Project) ([]ScheduleReport, []ProjectError) {
+	reports := make([][]ScheduleReport, len(projects))
+	projectErrs := fanOutProjects(projects, func(i int) error {
+		var err error
+		reports[i], err = projectScheduleReports(c, projects[i])
+		return err
+	})
+
+	var result []ScheduleReport
+	for _, r := range reports {
+		result = append(result, r...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Project.PathWithNamespace != result[j].Project.PathWithNamespace {
+			return result[i].Project.PathWithNamespace < result[j].Project.PathWithNamespace
+		}
+		return result[i].Schedule.ID < result[j].Schedule.ID
+	})
+	return result, projectErrs
+}
+
+var flagScheduleDescription string
+var flagScheduleRef string
+var flagScheduleCron string
+var flagScheduleTimezone string
+var flagScheduleActive bool
+var flagScheduleInactive bool
+var flagScheduleVars []string
+var flagScheduleVarFile string
+var flagScheduleRemoveVars []string
+var flagScheduleProblems bool
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage the pipeline schedules of a project",
+}
+
+var listSchedulesCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pipeline schedules of the given project [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		schedules, err := c.PipelineSchedules(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("ID", "Description", "Ref", "Cron", "Active", "Owner", "Next run")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, s := range schedules {
+			tbl.AddRow(s.ID, s.Description, s.Ref, fmt.Sprint(s.Cron, " ", s.CronTimezone), s.Active, scheduleOwner(s), scheduleNextRun(s))
+		}
+		tbl.Print()
+	},
+}
+
+var showScheduleCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show a pipeline schedule and its variables [namespace] [project-name] [schedule-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		printSchedule(requireSchedule(c, *p, args[2]), flagRevealVariables)
+	},
+}
+
+var createScheduleCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a pipeline schedule [namespace] [project-name] [description] [branch-or-tag] [cron]",
+	Long: `Create a pipeline schedule running a pipeline for a branch or tag, on a cron
+expression such as "0 4 * * *".
+
+Variables are given with --var KEY=VALUE, or read from a YAML or dotenv file
+with --var-file as used by ci-variables sync. The schedule is owned by the
+current user.`,
+	Args: cobra.ExactArgs(5),
+	Run: func(cmd *cobra.Command, args []string) {
+		variables, err := pipelineVariables(flagScheduleVarFile, flagScheduleVars)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+
+		active := !flagScheduleInactive
+		s := gitlab.PipelineScheduleSettings{Description: &args[2], Ref: &args[3], Cron: &args[4], Active: &active}
+		if cmd.Flag("timezone").Changed {
+			s.CronTimezone = &flagScheduleTimezone
+		}
+		schedule, err := c.CreatePipelineSchedule(*p, s)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if err = setScheduleVariables(c, *p, *schedule, variables, nil); nil != err {
+			log.Fatal(err.Error())
+		}
+		printSchedule(requireSchedule(c, *p, strconv.Itoa(schedule.ID)), false)
+	},
+}
+
+var updateScheduleCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a pipeline schedule [namespace] [project-name] [schedule-id]",
+	Long: `Update a pipeline schedule, only the given settings are changed.
+
+Variables given with --var or --var-file are added to the schedule or changed,
+variables given with --remove-var are removed from it.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		variables, err := pipelineVariables(flagScheduleVarFile, flagScheduleVars)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		schedule := requireSchedule(c, *p, args[2])
+
+		var s gitlab.PipelineScheduleSettings
+		if cmd.Flag("description").Changed {
+			s.Description = &flagScheduleDescription
+		}
+		if cmd.Flag("ref").Changed {
+			s.Ref = &flagScheduleRef
+		}
+		if cmd.Flag("cron").Changed {
+			s.Cron = &flagScheduleCron
+		}
+		if cmd.Flag("timezone").Changed {
+			s.CronTimezone = &flagScheduleTimezone
+		}
+		if cmd.Flag("active").Changed {
+			s.Active = &flagScheduleActive
+		}
+		if !s.HasChanges() && len(variables) == 0 && len(flagScheduleRemoveVars) == 0 {
+			log.Fatal(errors.New("Nothing to update"))
+		}
+
+		if err = c.EditPipelineSchedule(*p, schedule.ID, s); nil != err {
+			log.Fatal(err.Error())
+		}
+		if err = setScheduleVariables(c, *p, *schedule, variables, flagScheduleRemoveVars); nil != err {
+			log.Fatal(err.Error())
+		}
+		printSchedule(requireSchedule(c, *p, args[2]), false)
+	},
+}
+
+var deleteScheduleCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a pipeline schedule [namespace] [project-name] [schedule-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		schedule := requireSchedule(c, *p, args[2])
+		if err := c.RemovePipelineSchedule(*p, schedule.ID); nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("Deleted schedule #%d %s\n", schedule.ID, schedule.Description)
+	},
+}
+
+var takeOwnershipOfScheduleCmd = &cobra.Command{
+	Use:   "take-ownership",
+	Short: "Become the owner of a pipeline schedule [namespace] [project-name] [schedule-id]",
+	Long: `Become the owner of a pipeline schedule. Scheduled pipelines run as the owner,
+so a schedule of someone who left stops running until it is taken over.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		schedule := requireSchedule(c, *p, args[2])
+		schedule, err := c.TakeOwnershipOfPipelineSchedule(*p, schedule.ID)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("Schedule #%d %s is now owned by %s\n", schedule.ID, schedule.Description, scheduleOwner(schedule))
+	},
+}
+
+var runScheduleCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run a pipeline schedule now [namespace] [project-name] [schedule-id]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		schedule := requireSchedule(c, *p, args[2])
+		if err := c.RunPipelineSchedule(*p, schedule.ID); nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Printf("Started a pipeline for schedule #%d %s on %s\n", schedule.ID, schedule.Description, schedule.Ref)
+	},
+}
+
+var scheduleReportCmd = &cobra.Command{
+	Use:   "report-for-namespace",
+	Short: "Report the pipeline schedules of all projects in [namespace]",
+	Long: `Report the pipeline schedules of all projects in a namespace with the status of
+their last pipeline.
+
+Schedules are flagged when they are inactive, when their last pipeline failed,
+or when their owner departed: the account is blocked or deactivated, or no
+longer a member of the project. Scheduled pipelines of a departed owner fail to
+run, use take-ownership to fix that. Projects whose schedules fail to be looked
+up are listed after the report, and exit non-zero.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		var active []lab.Project
+		for _, p := range projects {
+			if p.Archived || p.BuildsAccessLevel == "disabled" {
+				continue
+			}
+			active = append(active, p)
+		}
+
+		reports, projectErrs := scheduleReports(c, active)
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Project", "ID", "Description", "Ref", "Cron", "Owner", "Last status", "Next run", "Problems")
+		tbl.WithHeaderFormatter(headerFmt)
+		problems := 0
+		for _, r := range reports {
+			if len(r.Problems) > 0 {
+				problems++
+			} else if flagScheduleProblems {
+				continue
+			}
+			s := r.Schedule
+			lastStatus := ""
+			if s.LastPipeline.ID != 0 {
+				lastStatus = s.LastPipeline.Status
+			}
+			tbl.AddRow(r.Project.PathWithNamespace, s.ID, s.Description, s.Ref, fmt.Sprint(s.Cron, " ", s.CronTimezone),
+				scheduleOwner(s), lastStatus, scheduleNextRun(s), strings.Join(r.Problems, ", "))
+		}
+		tbl.Print()
+		fmt.Println(len(reports), "schedules,", problems, "with problems")
+		printProjectErrors(projectErrs)
+		if len(projectErrs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	showScheduleCmd.Flags().BoolVar(&flagRevealVariables, "reveal", false, "print variable values instead of redacting them")
+
+	for _, cmd := range []*cobra.Command{createScheduleCmd, updateScheduleCmd} {
+		cmd.Flags().StringVar(&flagScheduleTimezone, "timezone", "", "timezone of the cron expression, such as Europe/Amsterdam, defaults to UTC")
+		cmd.Flags().StringArrayVarP(&flagScheduleVars, "var", "v", nil, "variable as KEY=VALUE, can be given multiple times")
+		cmd.Flags().StringVarP(&flagScheduleVarFile, "var-file", "f", "", "YAML or dotenv file to read variables from")
+	}
+	createScheduleCmd.Flags().BoolVar(&flagScheduleInactive, "inactive", false, "create the schedule without activating it")
+	updateScheduleCmd.Flags().StringVarP(&flagScheduleDescription, "description", "d", "", "description of the schedule")
+	updateScheduleCmd.Flags().StringVarP(&flagScheduleRef, "ref", "r", "", "branch or tag to run the pipeline for")
+	updateScheduleCmd.Flags().StringVarP(&flagScheduleCron, "cron", "c", "", "cron expression of when to run, such as \"0 4 * * *\"")
+	updateScheduleCmd.Flags().BoolVarP(&flagScheduleActive, "active", "a", false, "whether the schedule is active")
+	updateScheduleCmd.Flags().StringArrayVar(&flagScheduleRemoveVars, "remove-var", nil, "key of a variable to remove, can be given multiple times")
+
+	scheduleReportCmd.Flags().BoolVarP(&flagScheduleProblems, "problems", "p", false, "only show schedules with problems")
+
+	scheduleCmd.AddCommand(listSchedulesCmd)
+	scheduleCmd.AddCommand(showScheduleCmd)
+	scheduleCmd.AddCommand(createScheduleCmd)
+	scheduleCmd.AddCommand(updateScheduleCmd)
+	scheduleCmd.AddCommand(deleteScheduleCmd)
+	scheduleCmd.AddCommand(takeOwnershipOfScheduleCmd)
+	scheduleCmd.AddCommand(runScheduleCmd)
+	scheduleCmd.AddCommand(scheduleReportCmd)
+	pipelineCmd.AddCommand(scheduleCmd)
+}
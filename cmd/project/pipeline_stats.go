@@ -0,0 +1,278 @@
+package project
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// JobStats summarizes the finished runs of a job over a number of pipelines.
+type JobStats struct {
+	Name           string  `json:"name"`
+	Runs           int     `json:"runs"`
+	Failures       int     `json:"failures"`
+	FailureRate    float64 `json:"failure_rate"`
+	MedianDuration float64 `json:"median_duration_seconds"`
+	P95Duration    float64 `json:"p95_duration_seconds"`
+	// FlakyCommits is the number of commits on which the job both failed and succeeded.
+	FlakyCommits int `json:"flaky_commits"`
+}
+
+type PipelineStats struct {
+	Project     string     `json:"project"`
+	Ref         string     `json:"ref"`
+	Since       string     `json:"since"`
+	Pipelines   int        `json:"pipelines"`
+	Succeeded   int        `json:"succeeded"`
+	Failed      int        `json:"failed"`
+	Canceled    int        `json:"canceled"`
+	SuccessRate float64    `json:"success_rate"`
+	Jobs        []JobStats `json:"jobs"`
+}
+
+var jobStatsCSVHeader = []string{"name", "runs", "failures", "failure_rate", "median_duration_seconds", "p95_duration_seconds", "flaky_commits"}
+
+// percentile returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// pipelineJobs fetches the jobs of all pipelines concurrently, including retried jobs, keeping the order of the pipelines.
+func pipelineJobs(c *gitlab.GitlabClient, p lab.Project, pipelines []*lab.PipelineInfo) ([][]*lab.Job, error) {
+	result := make([][]*lab.Job, len(pipelines))
+	err := fanOut(len(pipelines), func(i int) error {
+		var err error
+		result[i], err = c.AllJobsForPipeline(p, *pipelines[i])
+		return err
+	})
+	if nil != err {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pipelineStats computes the statistics of the pipelines and their jobs. Only jobs that succeeded or failed are counted.
+func pipelineStats(pipelines []*lab.PipelineInfo, jobs [][]*lab.Job) PipelineStats {
+	stats := PipelineStats{Pipelines: len(pipelines)}
+	for _, pipeline := range pipelines {
+		switch pipeline.Status {
+		case "success":
+			stats.Succeeded++
+		case "failed":
+			stats.Failed++
+		case "canceled":
+			stats.Canceled++
+		}
+	}
+	stats.SuccessRate = ratio(stats.Succeeded, stats.Succeeded+stats.Failed+stats.Canceled)
+
+	type commitResult struct{ failed, succeeded bool }
+	byName := map[string]*JobStats{}
+	durations := map[string][]float64{}
+	commits := map[string]map[string]*commitResult{}
+	for i, pipelineJobs := range jobs {
+		for _, j := range pipelineJobs {
+			if j.Status != "success" && j.Status != "failed" {
+				continue
+			}
+			s, ok := byName[j.Name]
+			if !ok {
+				s = &JobStats{Name: j.Name}
+				byName[j.Name] = s
+				commits[j.Name] = map[string]*commitResult{}
+			}
+			s.Runs++
+			if j.Duration > 0 {
+				durations[j.Name] = append(durations[j.Name], j.Duration)
+			}
+
+			sha := pipelines[i].SHA
+			if _, ok := commits[j.Name][sha]; !ok {
+				commits[j.Name][sha] = &commitResult{}
+			}
+			if j.Status == "failed" {
+				s.Failures++
+				commits[j.Name][sha].failed = true
+			} else {
+				commits[j.Name][sha].succeeded = true
+			}
+		}
+	}
+
+	for name, s := range byName {
+		s.FailureRate = ratio(s.Failures, s.Runs)
+		d := durations[name]
+		sort.Float64s(d)
+		s.MedianDuration = percentile(d, 0.5)
+		s.P95Duration = percentile(d, 0.95)
+		for _, r := range commits[name] {
+			if r.failed && r.succeeded {
+				s.FlakyCommits++
+			}
+		}
+		stats.Jobs = append(stats.Jobs, *s)
+	}
+	sort.Slice(stats.Jobs, func(i, j int) bool {
+		if stats.Jobs[i].Failures != stats.Jobs[j].Failures {
+			return stats.Jobs[i].Failures > stats.Jobs[j].Failures
+		}
+		return stats.Jobs[i].Name < stats.Jobs[j].Name
+	})
+	return stats
+}
+
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+func formatPercentage(r float64) string {
+	return fmt.Sprintf("%.1f%%", r*100)
+}
+
+func writePipelineStats(w io.Writer, format string, stats PipelineStats) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(stats, "", "  ")
+		if nil != err {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(jobStatsCSVHeader)
+		for _, j := range stats.Jobs {
+			cw.Write([]string{
+				j.Name,
+				strconv.Itoa(j.Runs),
+				strconv.Itoa(j.Failures),
+				strconv.FormatFloat(j.FailureRate, 'f', 4, 64),
+				strconv.FormatFloat(j.MedianDuration, 'f', 1, 64),
+				strconv.FormatFloat(j.P95Duration, 'f', 1, 64),
+				strconv.Itoa(j.FlakyCommits),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		fmt.Fprintf(w, "%s on %s since %s\n", stats.Project, stats.Ref, stats.Since)
+		fmt.Fprintf(w, "%d pipelines, %d succeeded, %d failed, %d canceled, success rate %s\n\n",
+			stats.Pipelines, stats.Succeeded, stats.Failed, stats.Canceled, formatPercentage(stats.SuccessRate))
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Job", "Runs", "Failures", "Failure rate", "Median", "P95", "Flaky commits")
+		tbl.WithHeaderFormatter(headerFmt)
+		tbl.WithWriter(w)
+		for _, j := range stats.Jobs {
+			tbl.AddRow(j.Name, j.Runs, j.Failures, formatPercentage(j.FailureRate), formatSeconds(j.MedianDuration), formatSeconds(j.P95Duration), j.FlakyCommits)
+		}
+		tbl.Print()
+		return nil
+	default:
+		return fmt.Errorf("non valid format %s given", format)
+	}
+}
+
+var flagStatsSince string
+var flagStatsTop int
+var flagStatsFlakyOnly bool
+var flagStatsFormat string
+var flagStatsOutput string
+
+var pipelineStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics of the pipelines for a branch or tag [namespace] [project-name] [branch-or-tag]",
+	Long: `Show statistics of the pipelines for a branch or tag over a time window.
+
+Reports the pipeline success rate and, per job, how often it failed and its
+median and 95th percentile duration, with the most failing jobs first. A job
+is flaky on a commit when it both failed and succeeded for that commit, for
+example after a retry.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseAge(flagStatsSince)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		since := time.Now().Add(-window)
+
+		c := gitlab.NewClient()
+		p := requireProject(c, args[0], args[1])
+		pipelines, err := c.PipelinesSince(*p, args[2], since)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		jobs, err := pipelineJobs(c, *p, pipelines)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		stats := pipelineStats(pipelines, jobs)
+		stats.Project = p.PathWithNamespace
+		stats.Ref = args[2]
+		stats.Since = since.Format(time.RFC3339)
+		if flagStatsFlakyOnly {
+			var flaky []JobStats
+			for _, j := range stats.Jobs {
+				if j.FlakyCommits > 0 {
+					flaky = append(flaky, j)
+				}
+			}
+			stats.Jobs = flaky
+		}
+		if flagStatsTop > 0 && len(stats.Jobs) > flagStatsTop {
+			stats.Jobs = stats.Jobs[:flagStatsTop]
+		}
+
+		var w io.Writer = os.Stdout
+		if "" != flagStatsOutput {
+			f, err := os.Create(flagStatsOutput)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			defer f.Close()
+			w = f
+		}
+		if err = writePipelineStats(w, flagStatsFormat, stats); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+func init() {
+	pipelineStatsCmd.Flags().StringVarP(&flagStatsSince, "since", "s", "30d", "time window to include pipelines of, such as 30d, 2w or 12h")
+	pipelineStatsCmd.Flags().IntVarP(&flagStatsTop, "top", "n", 0, "only show this many of the most failing jobs, 0 for all")
+	pipelineStatsCmd.Flags().BoolVar(&flagStatsFlakyOnly, "flaky", false, "only show flaky jobs")
+	pipelineStatsCmd.Flags().StringVarP(&flagStatsFormat, "format", "f", "table", "report format. [table|csv|json]")
+	pipelineStatsCmd.Flags().StringVar(&flagStatsOutput, "output", "", "write the report to this file instead of stdout")
+
+	pipelineCmd.AddCommand(pipelineStatsCmd)
+}
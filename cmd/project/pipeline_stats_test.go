@@ -0,0 +1,28 @@
+package project
+
+import "testing"
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		name     string
+		sorted   []float64
+		p        float64
+		expected float64
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single", []float64{42}, 0.95, 42},
+		{"minimum", sorted, 0, 1},
+		{"median", sorted, 0.5, 5},
+		{"p90", sorted, 0.9, 9},
+		{"p95 rounds up", sorted, 0.95, 10},
+		{"maximum", sorted, 1, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := percentile(tt.sorted, tt.p); result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
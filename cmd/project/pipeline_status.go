@@ -0,0 +1,129 @@
+package project
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// ProjectPipelineStatus is the latest pipeline of the default branch of a project, nil if it has none.
+type ProjectPipelineStatus struct {
+	Project     lab.Project
+	Pipeline    *lab.Pipeline
+	FailingJobs []string
+}
+
+func projectPipelineStatus(c *gitlab.GitlabClient, p lab.Project) (ProjectPipelineStatus, error) {
+	status := ProjectPipelineStatus{Project: p}
+	pipelines, err := c.Pipelines(p, p.DefaultBranch)
+	if nil != err || len(pipelines) == 0 {
+		return status, err
+	}
+	status.Pipeline, err = c.Pipeline(p, pipelines[0].ID)
+	if nil != err {
+		return status, err
+	}
+	if status.Pipeline.Status != "failed" {
+		return status, nil
+	}
+
+	jobs, err := c.JobsForPipeline(p, *pipelines[0])
+	if nil != err {
+		return status, err
+	}
+	for _, j := range jobs {
+		if j.Status == "failed" && !j.AllowFailure {
+			status.FailingJobs = append(status.FailingJobs, j.Name)
+		}
+	}
+	sort.Strings(status.FailingJobs)
+	return status, nil
+}
+
+// projectPipelineStatuses resolves the pipeline status of all projects concurrently, sorted by project path.
+func projectPipelineStatuses(c *gitlab.GitlabClient, projects []lab.Project) ([]ProjectPipelineStatus, error) {
+	result := make([]ProjectPipelineStatus, len(projects))
+	err := fanOut(len(projects), func(i int) error {
+		var err error
+		result[i], err = projectPipelineStatus(c, projects[i])
+		return err
+	})
+	if nil != err {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Project.PathWithNamespace < result[j].Project.PathWithNamespace
+	})
+	return result, nil
+}
+
+var flagPipelineStatusFailed bool
+
+var pipelineStatusCmd = &cobra.Command{
+	Use:   "pipeline-status",
+	Short: "Show the latest pipeline of the default branch of all projects in [namespace]",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		var active []lab.Project
+		for _, p := range projects {
+			if p.Archived || p.BuildsAccessLevel == "disabled" || "" == p.DefaultBranch {
+				continue
+			}
+			active = append(active, p)
+		}
+
+		statuses, err := projectPipelineStatuses(c, active)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Project", "Status", "Age", "Duration", "Failing jobs", "Pipeline")
+		tbl.WithHeaderFormatter(headerFmt)
+		failed := 0
+		for _, s := range statuses {
+			if nil == s.Pipeline {
+				if !flagPipelineStatusFailed {
+					tbl.AddRow(s.Project.PathWithNamespace, "none", "", "", "", "")
+				}
+				continue
+			}
+			if s.Pipeline.Status == "failed" {
+				failed++
+			} else if flagPipelineStatusFailed {
+				continue
+			}
+			age := ""
+			if nil != s.Pipeline.CreatedAt {
+				age = formatAge(time.Since(*s.Pipeline.CreatedAt))
+			}
+			duration := ""
+			if s.Pipeline.Duration > 0 {
+				duration = (time.Duration(s.Pipeline.Duration) * time.Second).String()
+			}
+			tbl.AddRow(s.Project.PathWithNamespace, s.Pipeline.Status, age, duration, strings.Join(s.FailingJobs, ", "), s.Pipeline.WebURL)
+		}
+		tbl.Print()
+		fmt.Println(len(statuses), "projects,", failed, "failed")
+	},
+}
+
+func init() {
+	pipelineStatusCmd.Flags().BoolVarP(&flagPipelineStatusFailed, "failed", "f", false, "only show projects whose latest pipeline failed")
+
+	projectCmd.AddCommand(pipelineStatusCmd)
+}
@@ -0,0 +1,185 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// Exit codes of pipeline watch, so scripts can tell a pipeline that did not succeed from one that did not finish in time, or
+// from one that could not be looked up.
+const (
+	exitPipelineFailed  = 1
+	exitPipelineTimeout = 2
+	exitPipelineError   = 3
+)
+
+func jobStatusColor(status string) *color.Color {
+	switch status {
+	case "success":
+		return color.New(color.FgGreen)
+	case "failed":
+		return color.New(color.FgRed)
+	case "running":
+		return color.New(color.FgBlue)
+	case "pending", "created", "preparing", "waiting_for_resource", "scheduled":
+		return color.New(color.FgYellow)
+	case "manual":
+		return color.New(color.FgMagenta)
+	default:
+		return color.New(color.FgHiBlack)
+	}
+}
+
+// pipelineStages groups the jobs by stage, with the stages in the order they run.
+func pipelineStages(jobs []*lab.Job) ([]string, map[string][]*lab.Job) {
+	sorted := append([]*lab.Job{}, jobs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	var stages []string
+	byStage := map[string][]*lab.Job{}
+	for _, j := range sorted {
+		if _, ok := byStage[j.Stage]; !ok {
+			stages = append(stages, j.Stage)
+		}
+		byStage[j.Stage] = append(byStage[j.Stage], j)
+	}
+	return stages, byStage
+}
+
+func jobDuration(j *lab.Job) string {
+	if j.Duration > 0 {
+		return (time.Duration(j.Duration * float64(time.Second))).Round(time.Second).String()
+	}
+	if nil != j.StartedAt {
+		return time.Since(*j.StartedAt).Round(time.Second).String()
+	}
+	return ""
+}
+
+func printPipelineView(pipeline *lab.Pipeline, jobs []*lab.Job) {
+	sha := pipeline.SHA
+	if len(sha) > 8 {
+		sha = sha[:8]
+	}
+	fmt.Printf("Pipeline #%d on %s (%s): %s\n", pipeline.ID, pipeline.Ref, sha, jobStatusColor(pipeline.Status).Sprint(pipeline.Status))
+	fmt.Println(pipeline.WebURL)
+	fmt.Println()
+
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Stage", "Job", "Duration", "Status")
+	tbl.WithHeaderFormatter(headerFmt)
+	stages, byStage := pipelineStages(jobs)
+	for _, stage := range stages {
+		for i, j := range byStage[stage] {
+			stageName := ""
+			if i == 0 {
+				stageName = stage
+			}
+			status := j.Status
+			if j.AllowFailure && j.Status == "failed" {
+				status = "failed (allowed)"
+			}
+			// The status is the last column, so its color codes do not disturb the alignment.
+			tbl.AddRow(stageName, j.Name, jobDuration(j), jobStatusColor(j.Status).Sprint(status))
+		}
+	}
+	tbl.Print()
+}
+
+// watchPipeline follows the pipeline until it finishes, and returns the exit code matching its result. In a terminal the
+// view is redrawn after every poll, otherwise only status changes of jobs are printed, followed by the final view.
+func watchPipeline(c *gitlab.GitlabClient, p lab.Project, id int, interval, timeout time.Duration) int {
+	live := isatty.IsTerminal(os.Stdout.Fd())
+	statuses := map[int]string{}
+	var jobs []*lab.Job
+	pipeline, err := waitForPipeline(c, p, id, interval, timeout, func(pipeline *lab.Pipeline) error {
+		var err error
+		jobs, err = c.JobsForPipeline(p, lab.PipelineInfo{ID: pipeline.ID})
+		if nil != err {
+			return err
+		}
+		if live {
+			fmt.Print("\033[H\033[2J")
+			printPipelineView(pipeline, jobs)
+			return nil
+		}
+		stages, byStage := pipelineStages(jobs)
+		for _, stage := range stages {
+			for _, j := range byStage[stage] {
+				if statuses[j.ID] != j.Status {
+					statuses[j.ID] = j.Status
+					fmt.Printf("%s %s/%s: %s\n", time.Now().Format("15:04:05"), j.Stage, j.Name, j.Status)
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errPipelineTimeout) {
+		fmt.Println(err.Error())
+		return exitPipelineTimeout
+	}
+	if nil != err {
+		log.Print(err.Error())
+		return exitPipelineError
+	}
+
+	if !live {
+		fmt.Println()
+		printPipelineView(pipeline, jobs)
+	}
+	if pipeline.Status != "success" {
+		return exitPipelineFailed
+	}
+	return 0
+}
+
+var flagWatchInterval time.Duration
+var flagWatchTimeout time.Duration
+
+var pipelineWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch a pipeline until it finishes [namespace] [project-name] [branch-or-tag|pipeline-id]",
+	Long: `Watch a pipeline until it finishes, showing the status of its jobs per stage.
+
+Given a branch or tag, the latest pipeline for it is watched. Exits 0 when the
+pipeline succeeded, 1 when it failed, was canceled or otherwise did not
+succeed, 2 when it did not finish within the timeout, and 3 when the project,
+pipeline or jobs could not be looked up, such as on an API error while polling.`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil == err && nil == p {
+			err = errors.New("No project found")
+		}
+		if nil != err {
+			log.Print(err.Error())
+			os.Exit(exitPipelineError)
+		}
+		id, err := findPipelineID(c, *p, args[2])
+		if nil != err {
+			log.Print(err.Error())
+			os.Exit(exitPipelineError)
+		}
+		os.Exit(watchPipeline(c, *p, id, flagWatchInterval, flagWatchTimeout))
+	},
+}
+
+func init() {
+	pipelineWatchCmd.Flags().DurationVarP(&flagWatchInterval, "interval", "i", 5*time.Second, "time between polls")
+	pipelineWatchCmd.Flags().DurationVar(&flagWatchTimeout, "timeout", 0, "maximum time to wait for the pipeline, 0 to wait forever")
+
+	pipelineCmd.AddCommand(pipelineWatchCmd)
+}
@@ -1,6 +1,16 @@
 package project
 
-import "github.com/spf13/cobra"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
 
 var projectCmd = &cobra.Command{
 	Use:   "project",
@@ -10,3 +20,40 @@ var projectCmd = &cobra.Command{
 func Add(root *cobra.Command) {
 	root.AddCommand(projectCmd)
 }
+
+// resolveProjectPath resolves a project by its full path, such as namespace/project-name, exiting when there is none.
+func resolveProjectPath(c *gitlab.GitlabClient, projectPath string) *lab.Project {
+	p, err := findProjectPath(c, projectPath)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	return p
+}
+
+// findProjectPath looks up a project by its path with namespace, such as group/project.
+func findProjectPath(c *gitlab.GitlabClient, projectPath string) (*lab.Project, error) {
+	i := strings.LastIndex(projectPath, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("Project %s is not of the form namespace/project-name", projectPath)
+	}
+	p, err := c.Project(projectPath[:i], projectPath[i+1:])
+	if nil != err {
+		return nil, err
+	}
+	if nil == p {
+		return nil, fmt.Errorf("No project %s found", projectPath)
+	}
+	return p, nil
+}
+
+// requireProject resolves a project by its namespace and name, exiting when there is none.
+func requireProject(c *gitlab.GitlabClient, namespace, name string) *lab.Project {
+	p, err := c.Project(namespace, name)
+	if nil != err {
+		log.Fatal(err.Error())
+	}
+	if nil == p {
+		log.Fatal(errors.New("No project found"))
+	}
+	return p
+}
@@ -0,0 +1,373 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// Names of the push rules, in the order they are printed. They match the keys of the push-rules baseline in golab-config.
+var pushRuleNames = []string{
+	"commit-message-regex",
+	"commit-message-negative-regex",
+	"branch-name-regex",
+	"author-email-regex",
+	"file-name-regex",
+	"max-file-size",
+	"deny-delete-tag",
+	"member-check",
+	"prevent-secrets",
+	"commit-committer-check",
+	"reject-unsigned-commits",
+}
+
+// PushRuleDrift is a push rule of a project that differs from the baseline.
+type PushRuleDrift struct {
+	Project  lab.Project
+	Rule     string
+	Expected string
+	Actual   string
+}
+
+// pushRuleValues returns the value of every push rule. A project without push rules has all of them disabled.
+func pushRuleValues(r *lab.ProjectPushRules) map[string]string {
+	if nil == r {
+		r = &lab.ProjectPushRules{}
+	}
+	return map[string]string{
+		"commit-message-regex":          r.CommitMessageRegex,
+		"commit-message-negative-regex": r.CommitMessageNegativeRegex,
+		"branch-name-regex":             r.BranchNameRegex,
+		"author-email-regex":            r.AuthorEmailRegex,
+		"file-name-regex":               r.FileNameRegex,
+		"max-file-size":                 strconv.Itoa(r.MaxFileSize),
+		"deny-delete-tag":               strconv.FormatBool(r.DenyDeleteTag),
+		"member-check":                  strconv.FormatBool(r.MemberCheck),
+		"prevent-secrets":               strconv.FormatBool(r.PreventSecrets),
+		"commit-committer-check":        strconv.FormatBool(r.CommitCommitterCheck),
+		"reject-unsigned-commits":       strconv.FormatBool(r.RejectUnsignedCommits),
+	}
+}
+
+// pushRuleSettingValues returns the value of every push rule that is set in the settings.
+func pushRuleSettingValues(s gitlab.PushRuleSettings) map[string]string {
+	result := map[string]string{}
+	regexes := map[string]*string{
+		"commit-message-regex":          s.CommitMessageRegex,
+		"commit-message-negative-regex": s.CommitMessageNegativeRegex,
+		"branch-name-regex":             s.BranchNameRegex,
+		"author-email-regex":            s.AuthorEmailRegex,
+		"file-name-regex":               s.FileNameRegex,
+	}
+	for name, v := range regexes {
+		if nil != v {
+			result[name] = *v
+		}
+	}
+	bools := map[string]*bool{
+		"deny-delete-tag":         s.DenyDeleteTag,
+		"member-check":            s.MemberCheck,
+		"prevent-secrets":         s.PreventSecrets,
+		"commit-committer-check":  s.CommitCommitterCheck,
+		"reject-unsigned-commits": s.RejectUnsignedCommits,
+	}
+	for name, v := range bools {
+		if nil != v {
+			result[name] = strconv.FormatBool(*v)
+		}
+	}
+	if nil != s.MaxFileSize {
+		result["max-file-size"] = strconv.Itoa(*s.MaxFileSize)
+	}
+	return result
+}
+
+// pushRuleBaseline reads the push-rules baseline from golab-config.
+func pushRuleBaseline() gitlab.PushRuleSettings {
+	var baseline gitlab.PushRuleSettings
+	if err := viper.UnmarshalKey("push-rules", &baseline); nil != err {
+		log.Fatal(fmt.Sprint("invalid push-rules in config: ", err.Error()))
+	}
+	if !baseline.HasChanges() {
+		log.Fatal(errors.New("No push-rules baseline configured"))
+	}
+	return baseline
+}
+
+// pushRuleDrift returns the push rules of the project that differ from the settings.
+func pushRuleDrift(c *gitlab.GitlabClient, p lab.Project, s gitlab.PushRuleSettings) ([]PushRuleDrift, error) {
+	var result []PushRuleDrift
+	rules, err := c.PushRules(p)
+	if nil != err {
+		return result, err
+	}
+	actual := pushRuleValues(rules)
+	expected := pushRuleSettingValues(s)
+	for _, name := range pushRuleNames {
+		e, ok := expected[name]
+		if ok && e != actual[name] {
+			result = append(result, PushRuleDrift{Project: p, Rule: name, Expected: e, Actual: actual[name]})
+		}
+	}
+	return result, nil
+}
+
+func printPushRuleDrift(drift []PushRuleDrift) {
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Project", "Rule", "Expected", "Actual")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, d := range drift {
+		tbl.AddRow(d.Project.PathWithNamespace, d.Rule, d.Expected, d.Actual)
+	}
+	tbl.Print()
+}
+
+var flagPushRuleCommitMessageRegex string
+var flagPushRuleCommitMessageNegativeRegex string
+var flagPushRuleBranchNameRegex string
+var flagPushRuleAuthorEmailRegex string
+var flagPushRuleFileNameRegex string
+var flagPushRuleMaxFileSize int
+var flagPushRuleDenyDeleteTag bool
+var flagPushRuleMemberCheck bool
+var flagPushRulePreventSecrets bool
+var flagPushRuleCommitCommitterCheck bool
+var flagPushRuleRejectUnsignedCommits bool
+var flagPushRuleBaseline bool
+var flagPushRuleDryRun bool
+var flagPushRuleYes bool
+
+func pushRuleSettings(cmd *cobra.Command) gitlab.PushRuleSettings {
+	settings := gitlab.PushRuleSettings{}
+	if cmd.Flag("commit-message-regex").Changed {
+		settings.CommitMessageRegex = &flagPushRuleCommitMessageRegex
+	}
+	if cmd.Flag("commit-message-negative-regex").Changed {
+		settings.CommitMessageNegativeRegex = &flagPushRuleCommitMessageNegativeRegex
+	}
+	if cmd.Flag("branch-name-regex").Changed {
+		settings.BranchNameRegex = &flagPushRuleBranchNameRegex
+	}
+	if cmd.Flag("author-email-regex").Changed {
+		settings.AuthorEmailRegex = &flagPushRuleAuthorEmailRegex
+	}
+	if cmd.Flag("file-name-regex").Changed {
+		settings.FileNameRegex = &flagPushRuleFileNameRegex
+	}
+	if cmd.Flag("max-file-size").Changed {
+		settings.MaxFileSize = &flagPushRuleMaxFileSize
+	}
+	if cmd.Flag("deny-delete-tag").Changed {
+		settings.DenyDeleteTag = &flagPushRuleDenyDeleteTag
+	}
+	if cmd.Flag("member-check").Changed {
+		settings.MemberCheck = &flagPushRuleMemberCheck
+	}
+	if cmd.Flag("prevent-secrets").Changed {
+		settings.PreventSecrets = &flagPushRulePreventSecrets
+	}
+	if cmd.Flag("commit-committer-check").Changed {
+		settings.CommitCommitterCheck = &flagPushRuleCommitCommitterCheck
+	}
+	if cmd.Flag("reject-unsigned-commits").Changed {
+		settings.RejectUnsignedCommits = &flagPushRuleRejectUnsignedCommits
+	}
+	return settings
+}
+
+func addPushRuleFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&flagPushRuleCommitMessageRegex, "commit-message-regex", "", "commit messages must match this regex")
+	cmd.Flags().StringVar(&flagPushRuleCommitMessageNegativeRegex, "commit-message-negative-regex", "", "commit messages must not match this regex")
+	cmd.Flags().StringVar(&flagPushRuleBranchNameRegex, "branch-name-regex", "", "branch names must match this regex")
+	cmd.Flags().StringVar(&flagPushRuleAuthorEmailRegex, "author-email-regex", "", "commit author emails must match this regex")
+	cmd.Flags().StringVar(&flagPushRuleFileNameRegex, "file-name-regex", "", "reject files whose name matches this regex")
+	cmd.Flags().IntVar(&flagPushRuleMaxFileSize, "max-file-size", 0, "maximum file size in MB, 0 for unlimited")
+	cmd.Flags().BoolVar(&flagPushRuleDenyDeleteTag, "deny-delete-tag", false, "deny deleting tags")
+	cmd.Flags().BoolVar(&flagPushRuleMemberCheck, "member-check", false, "only allow commits by project members")
+	cmd.Flags().BoolVar(&flagPushRulePreventSecrets, "prevent-secrets", false, "reject files that are likely to contain secrets")
+	cmd.Flags().BoolVar(&flagPushRuleCommitCommitterCheck, "commit-committer-check", false, "only allow commits committed with a verified email of the pusher")
+	cmd.Flags().BoolVar(&flagPushRuleRejectUnsignedCommits, "reject-unsigned-commits", false, "reject commits that are not signed")
+}
+
+var pushRulesCmd = &cobra.Command{
+	Use:   "push-rules",
+	Short: "Show the push rules of the given project [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+		rules, err := c.PushRules(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == rules {
+			fmt.Println("No push rules")
+			return
+		}
+
+		values := pushRuleValues(rules)
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Rule", "Value")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, name := range pushRuleNames {
+			tbl.AddRow(name, values[name])
+		}
+		tbl.Print()
+	},
+}
+
+var setPushRulesCmd = &cobra.Command{
+	Use:   "set-push-rules",
+	Short: "Set the push rules of the given project [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := pushRuleSettings(cmd)
+		if !settings.HasChanges() {
+			log.Fatal(errors.New("No push rules given"))
+		}
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+		if err = c.SetPushRules(*p, settings); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var setPushRulesForNamespaceCmd = &cobra.Command{
+	Use:   "set-push-rules-for-namespace",
+	Short: "Set the push rules of all projects in [namespace], from flags or the configured baseline",
+	Long: `Set the push rules of all projects in a namespace.
+
+The push rules are taken from the flags, or with --baseline from the push-rules
+section in golab-config. Only projects whose push rules differ are changed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := pushRuleSettings(cmd)
+		if flagPushRuleBaseline {
+			if settings.HasChanges() {
+				log.Fatal(errors.New("Either give push rules or --baseline, not both"))
+			}
+			settings = pushRuleBaseline()
+		}
+		if !settings.HasChanges() {
+			log.Fatal(errors.New("No push rules given"))
+		}
+
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var drift []PushRuleDrift
+		var changed []lab.Project
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			projectDrift, err := pushRuleDrift(c, p, settings)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			if len(projectDrift) > 0 {
+				drift = append(drift, projectDrift...)
+				changed = append(changed, p)
+			}
+		}
+		if len(changed) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+		printPushRuleDrift(drift)
+		if flagPushRuleDryRun {
+			return
+		}
+		if !confirm(fmt.Sprint("Set push rules of ", len(changed), " projects"), flagPushRuleYes) {
+			return
+		}
+
+		for _, p := range changed {
+			fmt.Println(p.PathWithNamespace)
+			if err = c.SetPushRules(p, settings); nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+	},
+}
+
+var pushRulesDriftCmd = &cobra.Command{
+	Use:   "push-rules-drift",
+	Short: "Report push rules of all projects in [namespace] differing from the configured baseline",
+	Long: `Report push rules of all projects in a namespace differing from the
+push-rules baseline in golab-config, for example:
+
+  push-rules:
+    commit-message-regex: ^[A-Z]+-[0-9]+
+    prevent-secrets: true
+    max-file-size: 50
+
+Rules missing from the baseline are not checked. Exits non-zero when any
+project differs.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		baseline := pushRuleBaseline()
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var drift []PushRuleDrift
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			projectDrift, err := pushRuleDrift(c, p, baseline)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			drift = append(drift, projectDrift...)
+		}
+		if len(drift) == 0 {
+			fmt.Println("No drift")
+			return
+		}
+		printPushRuleDrift(drift)
+		os.Exit(1)
+	},
+}
+
+func init() {
+	addPushRuleFlags(setPushRulesCmd)
+	addPushRuleFlags(setPushRulesForNamespaceCmd)
+	setPushRulesForNamespaceCmd.Flags().BoolVar(&flagPushRuleBaseline, "baseline", false, "set the push-rules baseline from golab-config")
+	setPushRulesForNamespaceCmd.Flags().BoolVar(&flagPushRuleDryRun, "dry-run", false, "only show the changes")
+	setPushRulesForNamespaceCmd.Flags().BoolVarP(&flagPushRuleYes, "yes", "y", false, "apply the changes without asking for confirmation")
+
+	projectCmd.AddCommand(pushRulesCmd)
+	projectCmd.AddCommand(setPushRulesCmd)
+	projectCmd.AddCommand(setPushRulesForNamespaceCmd)
+	projectCmd.AddCommand(pushRulesDriftCmd)
+}
@@ -0,0 +1,139 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mvannes/golab/cmd/internal/civariable"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+)
+
+var flagVariableEnvironmentScope string
+
+var ciVariableCmd = &cobra.Command{
+	Use:   "ci-variable",
+	Short: "Create, update or delete project CI variables",
+}
+
+var setCIVariableCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Create or update a CI variable of the given project [namespace] [project-name] [key] [value]",
+	Args:  cobra.ExactArgs(4),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		err = c.SetCIVariable(*p, civariable.SettingsFromFlags(cmd, args[2], args[3]))
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var setCIVariableForNamespaceCmd = &cobra.Command{
+	Use:   "set-for-namespace",
+	Short: "Create or update a CI variable for all projects in [namespace] [key] [value]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		settings := civariable.SettingsFromFlags(cmd, args[1], args[2])
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			if p.BuildsAccessLevel == "disabled" {
+				continue
+			}
+
+			fmt.Println(p.PathWithNamespace)
+
+			err = c.SetCIVariable(p, settings)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+	},
+}
+
+var deleteCIVariableCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a CI variable of the given project [namespace] [project-name] [key]",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		err = c.RemoveCIVariable(*p, args[2], flagVariableEnvironmentScope)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var deleteCIVariableForNamespaceCmd = &cobra.Command{
+	Use:   "delete-for-namespace",
+	Short: "Delete a CI variable from all projects in [namespace] [key] that define it",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		projects, err := c.Projects(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		for _, p := range projects {
+			if p.Archived {
+				continue
+			}
+			if p.BuildsAccessLevel == "disabled" {
+				continue
+			}
+			v, err := c.CIVariable(p, args[1], flagVariableEnvironmentScope)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			if nil == v {
+				continue
+			}
+
+			fmt.Println(p.PathWithNamespace)
+
+			err = c.RemoveCIVariable(p, args[1], flagVariableEnvironmentScope)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+		}
+	},
+}
+
+func init() {
+	civariable.AddSettingsFlags(setCIVariableCmd)
+	civariable.AddSettingsFlags(setCIVariableForNamespaceCmd)
+	deleteCIVariableCmd.Flags().StringVarP(&flagVariableEnvironmentScope, "environment-scope", "e", "*", "environment scope of the variable")
+	deleteCIVariableForNamespaceCmd.Flags().StringVarP(&flagVariableEnvironmentScope, "environment-scope", "e", "*", "environment scope of the variable")
+
+	ciVariableCmd.AddCommand(setCIVariableCmd)
+	ciVariableCmd.AddCommand(setCIVariableForNamespaceCmd)
+	ciVariableCmd.AddCommand(deleteCIVariableCmd)
+	ciVariableCmd.AddCommand(deleteCIVariableForNamespaceCmd)
+	projectCmd.AddCommand(ciVariableCmd)
+}
@@ -0,0 +1,254 @@
+package project
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+func variableAsSettings(v *gitlab.CIVariable) gitlab.CIVariableSettings {
+	protected := v.Protected
+	masked := v.Masked
+	raw := v.Raw
+	return gitlab.CIVariableSettings{
+		Key:              v.Key,
+		Value:            v.Value,
+		VariableType:     string(v.VariableType),
+		EnvironmentScope: v.EnvironmentScope,
+		Protected:        &protected,
+		Masked:           &masked,
+		Raw:              &raw,
+	}
+}
+
+// diffCIVariablesForAllScopes diffs the variables for every environment scope in desired, and when pruning also for those only in current.
+func diffCIVariablesForAllScopes(current []*gitlab.CIVariable, desired []gitlab.CIVariableSettings, prune bool) []VariableChange {
+	desiredByScope := map[string][]gitlab.CIVariableSettings{}
+	for _, d := range desired {
+		desiredByScope[d.EnvironmentScope] = append(desiredByScope[d.EnvironmentScope], d)
+	}
+	if prune {
+		for _, v := range current {
+			if _, ok := desiredByScope[v.EnvironmentScope]; !ok {
+				desiredByScope[v.EnvironmentScope] = nil
+			}
+		}
+	}
+
+	var scopes []string
+	for scope := range desiredByScope {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	var result []VariableChange
+	for _, scope := range scopes {
+		result = append(result, diffCIVariables(current, desiredByScope[scope], scope, prune)...)
+	}
+	return result
+}
+
+// globPattern compiles a glob to a regex. Unlike path.Match a * also matches a /, so release* matches release/1.2.
+func globPattern(glob string) (*regexp.Regexp, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("glob %s has an unterminated [", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			pattern.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.Compile(pattern.String())
+}
+
+// compileGlobs compiles the globs with globPattern, so an invalid glob is reported before anything is matched against it.
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, g := range globs {
+		pattern, err := globPattern(g)
+		if nil != err {
+			return nil, err
+		}
+		result = append(result, pattern)
+	}
+	return result, nil
+}
+
+// matchesAnyGlob returns whether the name matches any of the globs compiled with compileGlobs.
+func matchesAnyGlob(globs []*regexp.Regexp, name string) bool {
+	for _, pattern := range globs {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+var flagCopyInclude []string
+var flagCopyExclude []string
+var flagCopyScopeMap []string
+var flagCopySkipExisting bool
+var flagCopyDryRun bool
+var flagCopyYes bool
+
+var copyVariablesCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy CI variables from one project to another [source-namespace/project-name] [destination-namespace/project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		scopeMap := map[string]string{}
+		for _, m := range flagCopyScopeMap {
+			parts := strings.SplitN(m, "=", 2)
+			if len(parts) != 2 {
+				log.Fatal(fmt.Errorf("Scope mapping %s is not of the form source=destination", m))
+			}
+			scopeMap[parts[0]] = parts[1]
+		}
+		include, err := compileGlobs(flagCopyInclude)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		exclude, err := compileGlobs(flagCopyExclude)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		c := gitlab.NewClient()
+		src := resolveProjectPath(c, args[0])
+		dst := resolveProjectPath(c, args[1])
+
+		srcVariables, err := c.CIVariables(*src)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		dstVariables, err := c.CIVariables(*dst)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var desired []gitlab.CIVariableSettings
+		for _, v := range srcVariables {
+			if len(include) > 0 && !matchesAnyGlob(include, v.Key) {
+				continue
+			}
+			if matchesAnyGlob(exclude, v.Key) {
+				continue
+			}
+			settings := variableAsSettings(v)
+			if scope, ok := scopeMap[v.EnvironmentScope]; ok {
+				settings.EnvironmentScope = scope
+			}
+			desired = append(desired, settings)
+		}
+
+		var changes []VariableChange
+		for _, change := range diffCIVariablesForAllScopes(dstVariables, desired, false) {
+			if flagCopySkipExisting && change.Action == UpdateVariable {
+				continue
+			}
+			changes = append(changes, change)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+		printVariableChanges(changes)
+		if flagCopyDryRun {
+			return
+		}
+		if !confirm(fmt.Sprint("Apply ", len(changes), " changes to ", dst.PathWithNamespace), flagCopyYes) {
+			return
+		}
+
+		if err = applyVariableChanges(c, *dst, changes); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+var diffVariablesCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the differences between the CI variables of two projects [namespace/project-name] [namespace/project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		a := resolveProjectPath(c, args[0])
+		b := resolveProjectPath(c, args[1])
+
+		aVariables, err := c.CIVariables(*a)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		bVariables, err := c.CIVariables(*b)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var desired []gitlab.CIVariableSettings
+		for _, v := range bVariables {
+			desired = append(desired, variableAsSettings(v))
+		}
+		changes := diffCIVariablesForAllScopes(aVariables, desired, true)
+		if len(changes) == 0 {
+			fmt.Println("No differences")
+			return
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Key", "Environment scope", "Difference")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, change := range changes {
+			var difference string
+			switch change.Action {
+			case AddVariable:
+				difference = fmt.Sprint("only in ", b.PathWithNamespace)
+			case RemoveVariable:
+				difference = fmt.Sprint("only in ", a.PathWithNamespace)
+			case UpdateVariable:
+				difference = strings.Join(change.Changes, ", ")
+			}
+			tbl.AddRow(change.Variable.Key, change.Variable.EnvironmentScope, difference)
+		}
+		tbl.Print()
+	},
+}
+
+func init() {
+	copyVariablesCmd.Flags().StringSliceVarP(&flagCopyInclude, "include", "i", nil, "only copy keys matching these globs")
+	copyVariablesCmd.Flags().StringSliceVarP(&flagCopyExclude, "exclude", "x", nil, "do not copy keys matching these globs")
+	copyVariablesCmd.Flags().StringSliceVarP(&flagCopyScopeMap, "scope-map", "s", nil, "map an environment scope to another, as source=destination")
+	copyVariablesCmd.Flags().BoolVar(&flagCopySkipExisting, "skip-existing", false, "do not overwrite variables already defined in the destination")
+	copyVariablesCmd.Flags().BoolVar(&flagCopyDryRun, "dry-run", false, "only show the changes")
+	copyVariablesCmd.Flags().BoolVarP(&flagCopyYes, "yes", "y", false, "apply the changes without asking for confirmation")
+
+	variableCmd.AddCommand(copyVariablesCmd)
+	variableCmd.AddCommand(diffVariablesCmd)
+}
@@ -0,0 +1,46 @@
+package project
+
+import "testing"
+
+func TestMatchesAnyGlob(t *testing.T) {
+	tests := []struct {
+		globs    []string
+		name     string
+		expected bool
+	}{
+		{[]string{"release*"}, "release/1.2", true},
+		{[]string{"release*"}, "release-1.2", true},
+		{[]string{"release*"}, "pre-release", false},
+		{[]string{"feature/*"}, "feature/a", true},
+		{[]string{"feature/*"}, "feature/a/b", true},
+		{[]string{"feature/*"}, "features/a", false},
+		{[]string{"*/wip"}, "team/alice/wip", true},
+		{[]string{"hotfix-?"}, "hotfix-1", true},
+		{[]string{"hotfix-?"}, "hotfix-12", false},
+		{[]string{"v[0-9]*"}, "v1.0", true},
+		{[]string{"v[!0-9]*"}, "v1.0", false},
+		{[]string{"a.b"}, "axb", false},
+		{[]string{`literal\*`}, "literal*", true},
+		{[]string{`literal\*`}, "literally", false},
+		{[]string{"main", "develop"}, "develop", true},
+		{nil, "main", false},
+		{[]string{"AWS_*"}, "AWS_SECRET_KEY", true},
+	}
+	for _, tt := range tests {
+		globs, err := compileGlobs(tt.globs)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if matched := matchesAnyGlob(globs, tt.name); matched != tt.expected {
+			t.Errorf("matchesAnyGlob(%q, %q) = %v, expected %v", tt.globs, tt.name, matched, tt.expected)
+		}
+	}
+}
+
+func TestCompileGlobsInvalid(t *testing.T) {
+	for _, globs := range [][]string{{"[prod"}, {"AWS_*", "[unterminated"}} {
+		if _, err := compileGlobs(globs); nil == err {
+			t.Errorf("expected an error for %q", globs)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/cmd/internal/civariable"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// VariableLevel holds the variables defined at a single level, a project or one of its groups.
+type VariableLevel struct {
+	Source    string
+	Variables []*lab.ProjectVariable
+}
+
+type EffectiveVariable struct {
+	Variable *lab.ProjectVariable
+	Source   string
+	// ShadowedBy is the source of the variable that takes precedence, empty if this variable is effective.
+	ShadowedBy string
+}
+
+func environmentScopeMatches(scope, environment string) bool {
+	pattern := strings.ReplaceAll(regexp.QuoteMeta(scope), `\*`, ".*")
+	return regexp.MustCompile("^" + pattern + "$").MatchString(environment)
+}
+
+// environmentScopeRank orders scopes from most to least specific for the environment.
+func environmentScopeRank(scope, environment string) int {
+	if scope == environment {
+		return 0
+	}
+	if scope == "*" {
+		return 2
+	}
+	return 1
+}
+
+// effectiveVariables resolves which variables a pipeline sees, given levels ordered from highest to lowest precedence.
+// Without an environment every environment scope is resolved separately, with an environment only variables whose scope
+// matches it are considered and the most specific scope within a level wins.
+func effectiveVariables(levels []VariableLevel, environment string) []EffectiveVariable {
+	var result []EffectiveVariable
+	winners := map[string]string{}
+	for _, level := range levels {
+		var variables []*lab.ProjectVariable
+		for _, v := range level.Variables {
+			if "" == environment || environmentScopeMatches(v.EnvironmentScope, environment) {
+				variables = append(variables, v)
+			}
+		}
+		sort.SliceStable(variables, func(i, j int) bool {
+			return environmentScopeRank(variables[i].EnvironmentScope, environment) < environmentScopeRank(variables[j].EnvironmentScope, environment)
+		})
+
+		for _, v := range variables {
+			identity := v.Key
+			if "" == environment {
+				identity = fmt.Sprint(v.Key, "\x00", v.EnvironmentScope)
+			}
+			winner, shadowed := winners[identity]
+			if !shadowed {
+				winners[identity] = level.Source
+			}
+			result = append(result, EffectiveVariable{Variable: v, Source: level.Source, ShadowedBy: winner})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Variable.Key != result[j].Variable.Key {
+			return result[i].Variable.Key < result[j].Variable.Key
+		}
+		return result[i].Variable.EnvironmentScope < result[j].Variable.EnvironmentScope
+	})
+	return result
+}
+
+var flagEffectiveEnvironment string
+var flagEffectiveHideShadowed bool
+
+var effectiveVariablesCmd = &cobra.Command{
+	Use:   "effective-variables",
+	Short: "Show the CI variables a pipeline of the project sees, including those of its groups [namespace] [project-name]",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		variables, err := c.CIVariables(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		levels := []VariableLevel{{Source: p.PathWithNamespace, Variables: projectVariables(variables)}}
+
+		groups, err := c.AncestorGroups(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		for _, group := range groups {
+			groupVariables, err := c.GroupCIVariables(*group)
+			if nil != err {
+				log.Fatal(err.Error())
+			}
+			levels = append(levels, VariableLevel{Source: group.FullPath, Variables: civariable.FromGroupVariables(groupVariables)})
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Key", "Value", "Environment scope", "Protected", "Masked", "Source", "Shadowed by")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, ev := range effectiveVariables(levels, flagEffectiveEnvironment) {
+			if flagEffectiveHideShadowed && "" != ev.ShadowedBy {
+				continue
+			}
+			pv := newProjectVariable(ev.Variable, flagRevealVariables)
+			tbl.AddRow(pv.Name, pv.Value, pv.EnvironmentScope, pv.Protected, pv.Masked, ev.Source, ev.ShadowedBy)
+		}
+		tbl.Print()
+	},
+}
+
+func init() {
+	effectiveVariablesCmd.Flags().BoolVar(&flagRevealVariables, "reveal", false, "print variable values instead of redacting them")
+	effectiveVariablesCmd.Flags().StringVarP(&flagEffectiveEnvironment, "environment", "e", "", "only resolve the variables for an environment, matched against the environment scopes of the variables")
+	effectiveVariablesCmd.Flags().BoolVar(&flagEffectiveHideShadowed, "hide-shadowed", false, "only show the variables that take effect")
+
+	projectCmd.AddCommand(effectiveVariablesCmd)
+}
@@ -0,0 +1,93 @@
+package project
+
+import (
+	"testing"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+func projectVariable(key, value, scope string) *lab.ProjectVariable {
+	return &lab.ProjectVariable{Key: key, Value: value, EnvironmentScope: scope}
+}
+
+type effectiveResult struct {
+	value, source, shadowedBy string
+}
+
+func effectiveResults(variables []EffectiveVariable) []effectiveResult {
+	var result []effectiveResult
+	for _, ev := range variables {
+		result = append(result, effectiveResult{ev.Variable.Value, ev.Source, ev.ShadowedBy})
+	}
+	return result
+}
+
+func TestEffectiveVariables(t *testing.T) {
+	levels := []VariableLevel{
+		{Source: "grp/project", Variables: []*lab.ProjectVariable{
+			projectVariable("TOKEN", "project", "*"),
+		}},
+		{Source: "grp", Variables: []*lab.ProjectVariable{
+			projectVariable("TOKEN", "group", "*"),
+			projectVariable("REGISTRY", "group-any", "*"),
+			projectVariable("REGISTRY", "group-production", "production"),
+		}},
+		{Source: "top", Variables: []*lab.ProjectVariable{
+			projectVariable("REGISTRY", "top-prod", "prod*"),
+			projectVariable("ONLY_TOP", "top", "staging"),
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		expected    []effectiveResult
+	}{
+		{
+			name: "every scope resolved separately",
+			expected: []effectiveResult{
+				{"top", "top", ""},
+				{"group-any", "grp", ""},
+				{"top-prod", "top", ""},
+				{"group-production", "grp", ""},
+				{"project", "grp/project", ""},
+				{"group", "grp", "grp/project"},
+			},
+		},
+		{
+			name:        "project takes precedence over groups",
+			environment: "staging",
+			expected: []effectiveResult{
+				{"top", "top", ""},
+				{"group-any", "grp", ""},
+				{"project", "grp/project", ""},
+				{"group", "grp", "grp/project"},
+			},
+		},
+		{
+			name:        "most specific scope wins within a level",
+			environment: "production",
+			expected: []effectiveResult{
+				{"group-any", "grp", "grp"},
+				{"top-prod", "top", "grp"},
+				{"group-production", "grp", ""},
+				{"project", "grp/project", ""},
+				{"group", "grp", "grp/project"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := effectiveResults(effectiveVariables(levels, tt.environment))
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, result)
+			}
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Errorf("expected %v, got %v", tt.expected, result)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package project
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Patterns used when no patterns are configured in golab-config.
+var defaultSecretKeyPatterns = []string{`(?i)token`, `(?i)passw(or)?d`, `(?i)secret`, `(?i)private_?key`, `(?i)api_?key`, `(?i)credential`}
+var defaultSecretValuePatterns = []string{`-----BEGIN [A-Z ]*PRIVATE KEY-----`, `^glpat-`, `^AKIA[0-9A-Z]{16}$`, `^gh[pousr]_[0-9A-Za-z]{36}`, `^xox[abprs]-`}
+var defaultProtectedKeyPatterns = []string{`(?i)prod`, `(?i)deploy`, `(?i)release`}
+
+type VariableFinding struct {
+	Project          string
+	Key              string
+	EnvironmentScope string
+	Finding          string
+}
+
+// configuredPatterns compiles the patterns configured under the given golab-config key, or the defaults if none are configured.
+func configuredPatterns(configKey string, defaults []string) []*regexp.Regexp {
+	patterns := viper.GetStringSlice(configKey)
+	if len(patterns) == 0 {
+		patterns = defaults
+	}
+	var result []*regexp.Regexp
+	for _, p := range patterns {
+		r, err := regexp.Compile(p)
+		if nil != err {
+			log.Fatal(fmt.Sprint("invalid pattern in ", configKey, ": ", err.Error()))
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, p := range patterns {
+		if p.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func lintCIVariables(namespaceVariables []NamespaceVariables, minDuplicates int) []VariableFinding {
+	secretKeys := configuredPatterns("ci-variable-secret-key-patterns", defaultSecretKeyPatterns)
+	secretValues := configuredPatterns("ci-variable-secret-value-patterns", defaultSecretValuePatterns)
+	protectedKeys := configuredPatterns("ci-variable-protected-key-patterns", defaultProtectedKeyPatterns)
+
+	var findings []VariableFinding
+	duplicates := map[string][]string{}
+	for _, nv := range namespaceVariables {
+		for _, v := range nv.Variables {
+			duplicateKey := fmt.Sprint(v.Key, "\x00", v.EnvironmentScope, "\x00", v.Value)
+			duplicates[duplicateKey] = append(duplicates[duplicateKey], nv.Project.PathWithNamespace)
+
+			if !v.Masked && (matchesAny(secretKeys, v.Key) || matchesAny(secretValues, v.Value)) {
+				findings = append(findings, VariableFinding{
+					Project:          nv.Project.PathWithNamespace,
+					Key:              v.Key,
+					EnvironmentScope: v.EnvironmentScope,
+					Finding:          "looks like a secret but is not masked",
+				})
+			}
+			if !v.Protected && (matchesAny(protectedKeys, v.Key) || matchesAny(protectedKeys, v.EnvironmentScope)) {
+				findings = append(findings, VariableFinding{
+					Project:          nv.Project.PathWithNamespace,
+					Key:              v.Key,
+					EnvironmentScope: v.EnvironmentScope,
+					Finding:          "looks protected branch only but is not protected",
+				})
+			}
+		}
+	}
+
+	for duplicateKey, projects := range duplicates {
+		if len(projects) < minDuplicates {
+			continue
+		}
+		parts := strings.SplitN(duplicateKey, "\x00", 3)
+		findings = append(findings, VariableFinding{
+			Project:          strings.Join(projects, ", "),
+			Key:              parts[0],
+			EnvironmentScope: parts[1],
+			Finding:          fmt.Sprint("same value in ", len(projects), " projects, promote to a group variable"),
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Key != findings[j].Key {
+			return findings[i].Key < findings[j].Key
+		}
+		return findings[i].Project < findings[j].Project
+	})
+	return findings
+}
+
+var flagLintMinDuplicates int
+
+var lintVariablesCmd = &cobra.Command{
+	Use:   "lint-ci-variables",
+	Short: "Lint the CI variables of all projects in [namespace]",
+	Long: `Lint the CI variables of all projects in a namespace.
+
+Reports variables defined with the same value in multiple projects, unmasked
+variables that look like secrets and variables that look like they are meant for
+protected branches only but are not protected. The patterns used can be
+configured in golab-config through ci-variable-secret-key-patterns,
+ci-variable-secret-value-patterns and ci-variable-protected-key-patterns.
+Exits non-zero when anything is found.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		namespaceVariables, err := namespaceCIVariables(c, args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		findings := lintCIVariables(namespaceVariables, flagLintMinDuplicates)
+		if len(findings) == 0 {
+			return
+		}
+
+		headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+		tbl := table.New("Key", "Environment scope", "Project", "Finding")
+		tbl.WithHeaderFormatter(headerFmt)
+		for _, f := range findings {
+			tbl.AddRow(f.Key, f.EnvironmentScope, f.Project, f.Finding)
+		}
+		tbl.Print()
+		os.Exit(1)
+	},
+}
+
+func init() {
+	lintVariablesCmd.Flags().IntVarP(&flagLintMinDuplicates, "min-duplicates", "d", 2, "number of projects sharing a variable before it is reported as duplicate")
+
+	projectCmd.AddCommand(lintVariablesCmd)
+}
@@ -0,0 +1,319 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+// RotationBackup holds the value of a variable before it was rotated, so the rotation can be rolled back.
+type RotationBackup struct {
+	Project          string `json:"project"`
+	Key              string `json:"key"`
+	EnvironmentScope string `json:"environment_scope"`
+	Value            string `json:"value"`
+}
+
+type RotationResult struct {
+	Project        lab.Project
+	Backup         RotationBackup
+	Status         string
+	PipelineURL    string
+	PipelineStatus string
+	Failed         bool
+}
+
+func writeRotationBackups(path string, backups []RotationBackup) error {
+	b, err := json.MarshalIndent(backups, "", "  ")
+	if nil != err {
+		return err
+	}
+	// The backup contains secrets, so it is only readable by the current user. An existing file could hold the only copy
+	// of the values before an earlier rotation, so it is never overwritten.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if nil != err {
+		return err
+	}
+	if _, err = f.Write(b); nil != err {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotationBackupFile returns the file given with --backup-file, or a new file named after the key and the current time.
+func rotationBackupFile(key string) string {
+	if "" != flagRotateBackupFile {
+		return flagRotateBackupFile
+	}
+	return fmt.Sprintf("rotation-backup-%s-%s.json", key, time.Now().Format("20060102-150405"))
+}
+
+func readRotationBackups(path string) ([]RotationBackup, error) {
+	var backups []RotationBackup
+	b, err := ioutil.ReadFile(path)
+	if nil != err {
+		return backups, err
+	}
+	err = json.Unmarshal(b, &backups)
+	return backups, err
+}
+
+// rotationValue returns the new value of the variable, read from the file given with --value-file or from stdin. It is
+// never taken from the arguments, so it does not end up in the shell history or the process list.
+func rotationValue() (string, error) {
+	var value string
+	if "" != flagRotateValueFile {
+		b, err := ioutil.ReadFile(flagRotateValueFile)
+		if nil != err {
+			return "", err
+		}
+		value = string(b)
+	} else if stat, err := os.Stdin.Stat(); nil == err && stat.Mode()&os.ModeCharDevice != 0 {
+		prompt := promptui.Prompt{Label: "New value", Mask: '*'}
+		if value, err = prompt.Run(); nil != err {
+			return "", err
+		}
+	} else {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if nil != err {
+			return "", err
+		}
+		value = string(b)
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+	if "" == value {
+		return "", fmt.Errorf("the new value is empty")
+	}
+	return value, nil
+}
+
+func restoreRotationBackup(c *gitlab.GitlabClient, p lab.Project, backup RotationBackup) error {
+	return c.SetCIVariable(p, gitlab.CIVariableSettings{
+		Key:              backup.Key,
+		Value:            backup.Value,
+		EnvironmentScope: backup.EnvironmentScope,
+	})
+}
+
+// verifyRotations runs a pipeline on the default branch of every rotated project, and waits for all of them to finish.
+func verifyRotations(c *gitlab.GitlabClient, results []*RotationResult, timeout time.Duration) {
+	pipelines := map[*RotationResult]*lab.Pipeline{}
+	for _, r := range results {
+		if r.Failed {
+			continue
+		}
+		pipeline, err := c.CreatePipeline(r.Project, r.Project.DefaultBranch, nil)
+		if nil != err {
+			r.PipelineStatus = err.Error()
+			r.Failed = true
+			continue
+		}
+		r.PipelineURL = pipeline.WebURL
+		pipelines[r] = pipeline
+	}
+
+	for _, r := range results {
+		pipeline, ok := pipelines[r]
+		if !ok {
+			continue
+		}
+		finished, err := waitForPipeline(c, r.Project, pipeline.ID, 10*time.Second, timeout, nil)
+		if nil != err {
+			r.PipelineStatus = err.Error()
+			r.Failed = true
+			continue
+		}
+		r.PipelineStatus = finished.Status
+		r.Failed = finished.Status != "success"
+	}
+}
+
+func printRotationResults(results []*RotationResult) {
+	headerFmt := color.New(color.BgBlue, color.Underline).SprintfFunc()
+	tbl := table.New("Project", "Status", "Pipeline status", "Pipeline")
+	tbl.WithHeaderFormatter(headerFmt)
+	for _, r := range results {
+		tbl.AddRow(r.Project.PathWithNamespace, r.Status, r.PipelineStatus, r.PipelineURL)
+	}
+	tbl.Print()
+}
+
+var flagRotateValueFile string
+var flagRotateBackupFile string
+var flagRotateVerify bool
+var flagRotateRollbackOnFailure bool
+var flagRotateTimeout time.Duration
+var flagRotateDryRun bool
+var flagRotateYes bool
+
+var rotateVariableCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate the value of a CI variable in all projects in a namespace defining it [namespace] [key]",
+	Long: `Rotate the value of a CI variable in all projects in a namespace defining it.
+
+The new value is read from --value-file, or from stdin, so it does not end up
+in the shell history or the process list. It is asked for when stdin is a
+terminal. Pass --yes when piping the value in, as the confirmation is read from
+stdin as well.
+
+The previous values are written to a new backup file before anything is
+changed, which can be used to restore them with the rollback command. An
+existing backup file is never overwritten. With --verify a pipeline is run on
+the default branch of every rotated project, and with --rollback-on-failure
+projects whose pipeline does not succeed get their previous value back. Exits
+non-zero when any project failed.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[1]
+		c := gitlab.NewClient()
+		namespaceVariables, err := namespaceCIVariables(c, args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		var results []*RotationResult
+		for _, nv := range namespaceVariables {
+			for _, v := range nv.Variables {
+				if v.Key != key || v.EnvironmentScope != flagVariableEnvironmentScope {
+					continue
+				}
+				results = append(results, &RotationResult{
+					Project: nv.Project,
+					Backup: RotationBackup{
+						Project:          nv.Project.PathWithNamespace,
+						Key:              v.Key,
+						EnvironmentScope: v.EnvironmentScope,
+						Value:            v.Value,
+					},
+				})
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("No projects define", key)
+			return
+		}
+
+		for _, r := range results {
+			fmt.Println(r.Project.PathWithNamespace)
+		}
+		if flagRotateDryRun {
+			return
+		}
+		value, err := rotationValue()
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if !confirm(fmt.Sprint("Rotate ", key, " in ", len(results), " projects"), flagRotateYes) {
+			return
+		}
+
+		var backups []RotationBackup
+		for _, r := range results {
+			backups = append(backups, r.Backup)
+		}
+		backupFile := rotationBackupFile(key)
+		if err = writeRotationBackups(backupFile, backups); nil != err {
+			log.Fatal(err.Error())
+		}
+		fmt.Println("Previous values written to", backupFile)
+
+		for _, r := range results {
+			err := c.SetCIVariable(r.Project, gitlab.CIVariableSettings{
+				Key:              key,
+				Value:            value,
+				EnvironmentScope: flagVariableEnvironmentScope,
+			})
+			if nil != err {
+				r.Status = err.Error()
+				r.Failed = true
+				continue
+			}
+			r.Status = "rotated"
+		}
+
+		if flagRotateVerify {
+			verifyRotations(c, results, flagRotateTimeout)
+		}
+
+		failed := false
+		for _, r := range results {
+			if !r.Failed {
+				continue
+			}
+			failed = true
+			if !flagRotateRollbackOnFailure || r.Status != "rotated" {
+				continue
+			}
+			if err := restoreRotationBackup(c, r.Project, r.Backup); nil != err {
+				r.Status = fmt.Sprint("rollback failed: ", err.Error())
+				continue
+			}
+			r.Status = "rolled back"
+		}
+
+		printRotationResults(results)
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var rollbackVariableCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore the CI variable values from a rotation backup file [backup-file]",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		backups, err := readRotationBackups(args[0])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		c := gitlab.NewClient()
+		var results []*RotationResult
+		failed := false
+		for _, backup := range backups {
+			r := &RotationResult{Project: lab.Project{PathWithNamespace: backup.Project}, Backup: backup, Status: "rolled back"}
+			results = append(results, r)
+			p, err := findProjectPath(c, backup.Project)
+			if nil == err {
+				err = restoreRotationBackup(c, *p, backup)
+			}
+			if nil != err {
+				r.Status = fmt.Sprint("rollback failed: ", err.Error())
+				failed = true
+			}
+		}
+
+		printRotationResults(results)
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rotateVariableCmd.Flags().StringVarP(&flagVariableEnvironmentScope, "environment-scope", "e", "*", "environment scope of the variable")
+	rotateVariableCmd.Flags().StringVar(&flagRotateValueFile, "value-file", "", "file to read the new value from, instead of stdin")
+	rotateVariableCmd.Flags().StringVarP(&flagRotateBackupFile, "backup-file", "b", "", "new file to write the previous values to, defaults to one named after the key and time")
+	rotateVariableCmd.Flags().BoolVar(&flagRotateVerify, "verify", false, "run a pipeline on the default branch of every rotated project")
+	rotateVariableCmd.Flags().BoolVar(&flagRotateRollbackOnFailure, "rollback-on-failure", false, "restore the previous value of projects that failed")
+	rotateVariableCmd.Flags().DurationVar(&flagRotateTimeout, "timeout", 30*time.Minute, "maximum time to wait for a verification pipeline, 0 to wait forever")
+	rotateVariableCmd.Flags().BoolVar(&flagRotateDryRun, "dry-run", false, "only show the projects that would be rotated")
+	rotateVariableCmd.Flags().BoolVarP(&flagRotateYes, "yes", "y", false, "rotate without asking for confirmation")
+
+	variableCmd.AddCommand(rotateVariableCmd)
+	variableCmd.AddCommand(rollbackVariableCmd)
+}
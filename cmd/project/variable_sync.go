@@ -0,0 +1,311 @@
+package project
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/mvannes/golab/cmd/internal/civariable"
+	"github.com/mvannes/golab/gitlab"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+type VariableAction string
+
+const (
+	AddVariable    VariableAction = "add"
+	UpdateVariable VariableAction = "update"
+	RemoveVariable VariableAction = "remove"
+)
+
+type VariableChange struct {
+	Action   VariableAction
+	Variable gitlab.CIVariableSettings
+	// Changes describes the changed attributes of an updated variable, values are never included.
+	Changes []string
+}
+
+// fileVariable is a single entry of a YAML variables file. It is either a plain value, or a mapping with the value and its attributes.
+type fileVariable struct {
+	Value     string `yaml:"value"`
+	Type      string `yaml:"type"`
+	Protected *bool  `yaml:"protected"`
+	Masked    *bool  `yaml:"masked"`
+	Raw       *bool  `yaml:"raw"`
+}
+
+func (f *fileVariable) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); nil == err {
+		f.Value = value
+		return nil
+	}
+	type plain fileVariable
+	return unmarshal((*plain)(f))
+}
+
+// readVariablesFile reads the variables in a YAML file, or in a dotenv file for any other extension.
+func readVariablesFile(path, environmentScope string) ([]gitlab.CIVariableSettings, error) {
+	var result []gitlab.CIVariableSettings
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		b, err := ioutil.ReadFile(path)
+		if nil != err {
+			return result, err
+		}
+		variables := map[string]fileVariable{}
+		if err = yaml.Unmarshal(b, &variables); nil != err {
+			return result, err
+		}
+		for key, v := range variables {
+			result = append(result, gitlab.CIVariableSettings{
+				Key:              key,
+				Value:            v.Value,
+				VariableType:     v.Type,
+				EnvironmentScope: environmentScope,
+				Protected:        v.Protected,
+				Masked:           v.Masked,
+				Raw:              v.Raw,
+			})
+		}
+	default:
+		values, err := readDotenvFile(path)
+		if nil != err {
+			return result, err
+		}
+		for key, value := range values {
+			result = append(result, gitlab.CIVariableSettings{Key: key, Value: value, EnvironmentScope: environmentScope})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+func readDotenvFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+	f, err := os.Open(path)
+	if nil != err {
+		return values, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return values, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if nil != err {
+				return values, fmt.Errorf("%s:%d: %s", path, lineNumber, err.Error())
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// diffCIVariables computes the changes needed to go from the current variables to the desired ones.
+// Only variables within the environment scope are considered, and unmanaged variables are only removed when pruning.
+func diffCIVariables(current []*gitlab.CIVariable, desired []gitlab.CIVariableSettings, environmentScope string, prune bool) []VariableChange {
+	existing := map[string]*gitlab.CIVariable{}
+	for _, v := range current {
+		if v.EnvironmentScope == environmentScope {
+			existing[v.Key] = v
+		}
+	}
+
+	var result []VariableChange
+	managed := map[string]bool{}
+	for _, d := range desired {
+		managed[d.Key] = true
+		v, ok := existing[d.Key]
+		if !ok {
+			result = append(result, VariableChange{Action: AddVariable, Variable: d})
+			continue
+		}
+
+		var changes []string
+		if v.Value != d.Value {
+			changes = append(changes, "value")
+		}
+		if "" != d.VariableType && string(v.VariableType) != d.VariableType {
+			changes = append(changes, fmt.Sprint("type: ", v.VariableType, " -> ", d.VariableType))
+		}
+		if nil != d.Protected && v.Protected != *d.Protected {
+			changes = append(changes, fmt.Sprint("protected: ", v.Protected, " -> ", *d.Protected))
+		}
+		if nil != d.Masked && v.Masked != *d.Masked {
+			changes = append(changes, fmt.Sprint("masked: ", v.Masked, " -> ", *d.Masked))
+		}
+		if nil != d.Raw && v.Raw != *d.Raw {
+			changes = append(changes, fmt.Sprint("raw: ", v.Raw, " -> ", *d.Raw))
+		}
+		if len(changes) > 0 {
+			result = append(result, VariableChange{Action: UpdateVariable, Variable: d, Changes: changes})
+		}
+	}
+
+	if prune {
+		var keys []string
+		for key := range existing {
+			if !managed[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, VariableChange{
+				Action:   RemoveVariable,
+				Variable: gitlab.CIVariableSettings{Key: key, EnvironmentScope: environmentScope},
+			})
+		}
+	}
+
+	return result
+}
+
+func printVariableChanges(changes []VariableChange) {
+	add := color.New(color.FgGreen).SprintFunc()
+	update := color.New(color.FgYellow).SprintFunc()
+	remove := color.New(color.FgRed).SprintFunc()
+	for _, change := range changes {
+		v := change.Variable
+		switch change.Action {
+		case AddVariable:
+			fmt.Println(add("+ ", v.Key, "=", civariable.Redacted, " (", v.EnvironmentScope, ")"))
+		case UpdateVariable:
+			fmt.Println(update("~ ", v.Key, " (", v.EnvironmentScope, "): ", strings.Join(change.Changes, ", ")))
+		case RemoveVariable:
+			fmt.Println(remove("- ", v.Key, " (", v.EnvironmentScope, ")"))
+		}
+	}
+}
+
+func applyVariableChanges(c *gitlab.GitlabClient, p lab.Project, changes []VariableChange) error {
+	for _, change := range changes {
+		var err error
+		if change.Action == RemoveVariable {
+			err = c.RemoveCIVariable(p, change.Variable.Key, change.Variable.EnvironmentScope)
+		} else {
+			err = c.SetCIVariable(p, change.Variable)
+		}
+		if nil != err {
+			return fmt.Errorf("%s %s: %s", change.Action, change.Variable.Key, err.Error())
+		}
+	}
+	return nil
+}
+
+// confirm asks the user for confirmation through a prompt, unless skip is set.
+func confirm(label string, skip bool) bool {
+	if skip {
+		return true
+	}
+	p := promptui.Select{
+		Label: label,
+		Items: []string{"yes", "no"},
+	}
+	_, i, err := p.Run()
+	if nil != err {
+		log.Fatal(err)
+	}
+	return i == "yes"
+}
+
+var flagSyncFile string
+var flagSyncPrune bool
+var flagSyncDryRun bool
+var flagSyncYes bool
+
+var syncVariablesCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync the CI variables of a project [namespace] [project-name] with a YAML or dotenv file",
+	Long: `Sync the CI variables of a project with a YAML or dotenv file.
+
+A YAML file maps keys to either a value, or to a mapping with a value and the
+type, protected, masked and raw attributes. Any other file is read as dotenv.
+Only variables in the given environment scope are synced.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c := gitlab.NewClient()
+		p, err := c.Project(args[0], args[1])
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		if nil == p {
+			log.Fatal(errors.New("No project found"))
+		}
+
+		desired, err := readVariablesFile(flagSyncFile, flagVariableEnvironmentScope)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+		for _, d := range desired {
+			if "" == d.Key {
+				log.Fatal(errors.New("Variables file contains an empty key"))
+			}
+		}
+
+		current, err := c.CIVariables(*p)
+		if nil != err {
+			log.Fatal(err.Error())
+		}
+
+		changes := diffCIVariables(current, desired, flagVariableEnvironmentScope, flagSyncPrune)
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+		printVariableChanges(changes)
+		if flagSyncDryRun {
+			return
+		}
+		if !confirm(fmt.Sprint("Apply ", len(changes), " changes to ", p.PathWithNamespace), flagSyncYes) {
+			return
+		}
+
+		if err = applyVariableChanges(c, *p, changes); nil != err {
+			log.Fatal(err.Error())
+		}
+	},
+}
+
+func init() {
+	syncVariablesCmd.Flags().StringVarP(&flagSyncFile, "file", "f", "", "MUST PROVIDE, the YAML or dotenv file to sync from")
+	syncVariablesCmd.Flags().StringVarP(&flagVariableEnvironmentScope, "environment-scope", "e", "*", "environment scope of the synced variables")
+	syncVariablesCmd.Flags().BoolVar(&flagSyncPrune, "prune", false, "delete variables in the environment scope that are not in the file")
+	syncVariablesCmd.Flags().BoolVar(&flagSyncDryRun, "dry-run", false, "only show the changes")
+	syncVariablesCmd.Flags().BoolVarP(&flagSyncYes, "yes", "y", false, "apply the changes without asking for confirmation")
+	syncVariablesCmd.MarkFlagRequired("file")
+
+	variableCmd.AddCommand(syncVariablesCmd)
+}
@@ -0,0 +1,130 @@
+package project
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mvannes/golab/gitlab"
+
+	lab "github.com/xanzy/go-gitlab"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); nil != err {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadDotenvFile(t *testing.T) {
+	path := writeTempFile(t, ".env", `# comment
+
+PLAIN=value
+export EXPORTED=1
+  SPACED  =  padded
+DOUBLE="line\nbreak"
+SINGLE='not\nescaped'
+EQUALS=a=b
+EMPTY=
+`)
+	values, err := readDotenvFile(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "1",
+		"SPACED":   "padded",
+		"DOUBLE":   "line\nbreak",
+		"SINGLE":   `not\nescaped`,
+		"EQUALS":   "a=b",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+}
+
+func TestReadDotenvFileErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing equals":   "KEY\n",
+		"bad double quote": `KEY="\q"` + "\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readDotenvFile(writeTempFile(t, ".env", content)); nil == err {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func ciVariable(key, value, scope string, protected, masked, raw bool) *gitlab.CIVariable {
+	return &gitlab.CIVariable{
+		ProjectVariable: lab.ProjectVariable{
+			Key:              key,
+			Value:            value,
+			VariableType:     lab.EnvVariableType,
+			EnvironmentScope: scope,
+			Protected:        protected,
+			Masked:           masked,
+		},
+		Raw: raw,
+	}
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func TestDiffCIVariables(t *testing.T) {
+	current := []*gitlab.CIVariable{
+		ciVariable("SAME", "v", "*", false, false, false),
+		ciVariable("VALUE", "old", "*", false, false, false),
+		ciVariable("FLAGS", "v", "*", false, false, false),
+		ciVariable("UNMANAGED", "v", "*", false, false, false),
+		ciVariable("OTHER_SCOPE", "v", "production", false, false, false),
+	}
+	desired := []gitlab.CIVariableSettings{
+		{Key: "SAME", Value: "v", EnvironmentScope: "*"},
+		{Key: "VALUE", Value: "new", EnvironmentScope: "*"},
+		{Key: "FLAGS", Value: "v", EnvironmentScope: "*", Protected: boolPointer(true), Masked: boolPointer(false), Raw: boolPointer(true)},
+		{Key: "NEW", Value: "v", EnvironmentScope: "*"},
+	}
+
+	tests := []struct {
+		name     string
+		prune    bool
+		expected []VariableChange
+	}{
+		{
+			name: "without pruning",
+			expected: []VariableChange{
+				{Action: UpdateVariable, Variable: desired[1], Changes: []string{"value"}},
+				{Action: UpdateVariable, Variable: desired[2], Changes: []string{"protected: false -> true", "raw: false -> true"}},
+				{Action: AddVariable, Variable: desired[3]},
+			},
+		},
+		{
+			name:  "with pruning",
+			prune: true,
+			expected: []VariableChange{
+				{Action: UpdateVariable, Variable: desired[1], Changes: []string{"value"}},
+				{Action: UpdateVariable, Variable: desired[2], Changes: []string{"protected: false -> true", "raw: false -> true"}},
+				{Action: AddVariable, Variable: desired[3]},
+				{Action: RemoveVariable, Variable: gitlab.CIVariableSettings{Key: "UNMANAGED", EnvironmentScope: "*"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffCIVariables(current, desired, "*", tt.prune)
+			if !reflect.DeepEqual(changes, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, changes)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/mvannes/golab/cmd/group"
 	mergerequest "github.com/mvannes/golab/cmd/merge_request"
 	"github.com/mvannes/golab/cmd/project"
 
@@ -25,6 +26,7 @@ func Execute() error {
 func init() {
 	project.Add(rootCmd)
 	mergerequest.Add(rootCmd)
+	group.Add(rootCmd)
 	dir, err := os.UserHomeDir()
 	if nil != err {
 		log.Fatal(err)
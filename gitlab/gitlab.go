@@ -1,10 +1,15 @@
 package gitlab
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -63,7 +68,7 @@ func (g *GitlabClient) Projects(namespace string) ([]gitlab.Project, error) {
 
 	for i := 1; i <= r.TotalPages; i++ {
 		projWg.Add(1)
-		go doProjectListRequest(*g.gitlab, c, i)
+		go doProjectListRequest(g.gitlab, c, i)
 	}
 	go func() {
 		projWg.Wait()
@@ -80,7 +85,7 @@ func (g *GitlabClient) Projects(namespace string) ([]gitlab.Project, error) {
 	return result, nil
 }
 
-func doProjectListRequest(c gitlab.Client, projectChan chan gitlab.Project, page int) {
+func doProjectListRequest(c *gitlab.Client, projectChan chan gitlab.Project, page int) {
 	defer projWg.Done()
 	projects, _, err := c.Projects.ListProjects(&gitlab.ListProjectsOptions{ListOptions: gitlab.ListOptions{PerPage: 50, Page: page}})
 
@@ -106,7 +111,7 @@ func (g *GitlabClient) Branches(p gitlab.Project) ([]gitlab.Branch, error) {
 
 	for i := 1; i <= r.TotalPages; i++ {
 		branchWg.Add(1)
-		go doBranchListRequest(*g.gitlab, c, p, i)
+		go doBranchListRequest(g.gitlab, c, p, i)
 	}
 	go func() {
 		branchWg.Wait()
@@ -120,7 +125,7 @@ func (g *GitlabClient) Branches(p gitlab.Project) ([]gitlab.Branch, error) {
 	return result, nil
 }
 
-func doBranchListRequest(c gitlab.Client, branchChan chan gitlab.Branch, p gitlab.Project, page int) {
+func doBranchListRequest(c *gitlab.Client, branchChan chan gitlab.Branch, p gitlab.Project, page int) {
 	defer branchWg.Done()
 	branches, _, err := c.Branches.ListBranches(
 		p.ID,
@@ -199,6 +204,161 @@ func (g *GitlabClient) RemoveBranch(p gitlab.Project, b gitlab.Branch) error {
 	return err
 }
 
+func (g *GitlabClient) CreateBranch(p gitlab.Project, name, ref string) (*gitlab.Branch, error) {
+	branch, _, err := g.gitlab.Branches.CreateBranch(p.ID, &gitlab.CreateBranchOptions{Branch: &name, Ref: &ref})
+	return branch, err
+}
+
+// ProtectedBranch returns the protection of the branch by its exact name, or nil if there is none.
+func (g *GitlabClient) ProtectedBranch(p gitlab.Project, name string) (*gitlab.ProtectedBranch, error) {
+	pb, resp, err := g.gitlab.ProtectedBranches.GetProtectedBranch(p.ID, name)
+	if nil != resp && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return pb, err
+}
+
+// ProtectedBranches returns all protections of the project, including those by a wildcard such as release/*.
+func (g *GitlabClient) ProtectedBranches(p gitlab.Project) ([]*gitlab.ProtectedBranch, error) {
+	opts := &gitlab.ListProtectedBranchesOptions{PerPage: 100}
+	var result []*gitlab.ProtectedBranch
+	for {
+		pbs, r, err := g.gitlab.ProtectedBranches.ListProtectedBranches(p.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, pbs...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+func branchPermissions(levels []*gitlab.BranchAccessDescription) *[]*gitlab.BranchPermissionOptions {
+	if len(levels) == 0 {
+		return nil
+	}
+	var result []*gitlab.BranchPermissionOptions
+	for _, l := range levels {
+		l := l
+		permission := &gitlab.BranchPermissionOptions{}
+		switch {
+		case 0 != l.UserID:
+			permission.UserID = &l.UserID
+		case 0 != l.GroupID:
+			permission.GroupID = &l.GroupID
+		default:
+			permission.AccessLevel = &l.AccessLevel
+		}
+		result = append(result, permission)
+	}
+	return &result
+}
+
+// CopyBranchProtection protects the named branch with the same rules as the given protection.
+func (g *GitlabClient) CopyBranchProtection(p gitlab.Project, pb gitlab.ProtectedBranch, name string) error {
+	_, _, err := g.gitlab.ProtectedBranches.ProtectRepositoryBranches(p.ID, &gitlab.ProtectRepositoryBranchesOptions{
+		Name:                      &name,
+		AllowForcePush:            &pb.AllowForcePush,
+		AllowedToPush:             branchPermissions(pb.PushAccessLevels),
+		AllowedToMerge:            branchPermissions(pb.MergeAccessLevels),
+		AllowedToUnprotect:        branchPermissions(pb.UnprotectAccessLevels),
+		CodeOwnerApprovalRequired: &pb.CodeOwnerApprovalRequired,
+	})
+	return err
+}
+
+func (g *GitlabClient) RemoveBranchProtection(p gitlab.Project, name string) error {
+	_, err := g.gitlab.ProtectedBranches.UnprotectRepositoryBranches(p.ID, name)
+	return err
+}
+
+func (g *GitlabClient) SetDefaultBranch(p gitlab.Project, name string) error {
+	_, _, err := g.gitlab.Projects.EditProject(p.ID, &gitlab.EditProjectOptions{DefaultBranch: &name})
+	return err
+}
+
+func (g *GitlabClient) RetargetMergeRequest(p gitlab.Project, mr gitlab.MergeRequest, target string) error {
+	_, _, err := g.gitlab.MergeRequests.UpdateMergeRequest(p.ID, mr.IID, &gitlab.UpdateMergeRequestOptions{TargetBranch: &target})
+	return err
+}
+
+// RecreateMergeRequest opens a copy of the merge request from another source branch, and closes the original pointing to
+// the copy. GitLab does not allow changing the source branch of a merge request.
+func (g *GitlabClient) RecreateMergeRequest(p gitlab.Project, mr gitlab.MergeRequest, source string) (*gitlab.MergeRequest, error) {
+	opts := &gitlab.CreateMergeRequestOptions{
+		Title:              &mr.Title,
+		Description:        &mr.Description,
+		SourceBranch:       &source,
+		TargetBranch:       &mr.TargetBranch,
+		TargetProjectID:    &mr.TargetProjectID,
+		RemoveSourceBranch: &mr.ForceRemoveSourceBranch,
+		Squash:             &mr.Squash,
+		AllowCollaboration: &mr.AllowCollaboration,
+	}
+	if len(mr.Labels) > 0 {
+		labels := gitlab.Labels(mr.Labels)
+		opts.Labels = &labels
+	}
+	var assignees, reviewers []int
+	for _, u := range mr.Assignees {
+		assignees = append(assignees, u.ID)
+	}
+	for _, u := range mr.Reviewers {
+		reviewers = append(reviewers, u.ID)
+	}
+	if len(assignees) > 0 {
+		opts.AssigneeIDs = &assignees
+	}
+	if len(reviewers) > 0 {
+		opts.ReviewerIDs = &reviewers
+	}
+	if nil != mr.Milestone {
+		opts.MilestoneID = &mr.Milestone.ID
+	}
+
+	created, _, err := g.gitlab.MergeRequests.CreateMergeRequest(p.ID, opts)
+	if nil != err {
+		return nil, err
+	}
+
+	note := fmt.Sprint("Source branch renamed to `", source, "`, continued in !", created.IID)
+	if _, _, err = g.gitlab.Notes.CreateMergeRequestNote(mr.ProjectID, mr.IID, &gitlab.CreateMergeRequestNoteOptions{Body: &note}); nil != err {
+		return created, err
+	}
+	closeEvent := "close"
+	_, _, err = g.gitlab.MergeRequests.UpdateMergeRequest(mr.ProjectID, mr.IID, &gitlab.UpdateMergeRequestOptions{StateEvent: &closeEvent})
+	return created, err
+}
+
+func (g *GitlabClient) ProjectMergeRequests(p gitlab.Project, state MergeRequestState) ([]*gitlab.MergeRequest, error) {
+	opts := &gitlab.ListProjectMergeRequestsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+	if state != All {
+		optState := string(state)
+		opts.State = &optState
+	}
+
+	var result []*gitlab.MergeRequest
+	for {
+		mrs, r, err := g.gitlab.MergeRequests.ListProjectMergeRequests(p.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, mrs...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// Compare returns the commits and diffs of to since its merge base with from.
+func (g *GitlabClient) Compare(p gitlab.Project, from, to string) (*gitlab.Compare, error) {
+	compare, _, err := g.gitlab.Repositories.Compare(p.ID, &gitlab.CompareOptions{From: &from, To: &to})
+	return compare, err
+}
+
 type ProjectSettings struct {
 	RemoveSourceBranchAfterMerge *bool
 	SquashOption                 *string
@@ -280,9 +440,201 @@ func (g *GitlabClient) UpdateJiraIntegration(p gitlab.Project, s ProjectJiraSett
 	return err
 }
 
-func (g *GitlabClient) CIVariables(p gitlab.Project) ([]*gitlab.ProjectVariable, error) {
-	variables, _, err := g.gitlab.ProjectVariables.ListVariables(p.ID, &gitlab.ListProjectVariablesOptions{})
-	return variables, err
+// CIVariable is a project variable including whether it is raw, which go-gitlab does not support.
+type CIVariable struct {
+	gitlab.ProjectVariable
+	Raw bool `json:"raw"`
+}
+
+func (g *GitlabClient) CIVariables(p gitlab.Project) ([]*CIVariable, error) {
+	opts := &gitlab.ListProjectVariablesOptions{PerPage: 100, Page: 1}
+	var result []*CIVariable
+	for {
+		req, err := g.gitlab.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/variables", p.ID), opts, nil)
+		if nil != err {
+			return result, err
+		}
+		var variables []*CIVariable
+		r, err := g.gitlab.Do(req, &variables)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, variables...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+type CIVariableSettings struct {
+	Key              string
+	Value            string
+	VariableType     string
+	EnvironmentScope string
+	Protected        *bool
+	Masked           *bool
+	Raw              *bool
+}
+
+// ciVariableOptions is used instead of the go-gitlab create and update options, as those do not support raw variables.
+type ciVariableOptions struct {
+	Key              *string                `json:"key,omitempty"`
+	Value            *string                `json:"value,omitempty"`
+	VariableType     *string                `json:"variable_type,omitempty"`
+	Protected        *bool                  `json:"protected,omitempty"`
+	Masked           *bool                  `json:"masked,omitempty"`
+	Raw              *bool                  `json:"raw,omitempty"`
+	EnvironmentScope *string                `json:"environment_scope,omitempty"`
+	Filter           *gitlab.VariableFilter `json:"filter,omitempty"`
+}
+
+func (s *CIVariableSettings) scope() string {
+	if "" == s.EnvironmentScope {
+		return "*"
+	}
+	return s.EnvironmentScope
+}
+
+func (s *CIVariableSettings) validate() error {
+	if "" == s.Key {
+		return errors.New("no variable key given")
+	}
+	switch s.VariableType {
+	case "", string(gitlab.EnvVariableType), string(gitlab.FileVariableType):
+	default:
+		return errors.New("non valid variable type given")
+	}
+	return nil
+}
+
+func (g *GitlabClient) CIVariable(p gitlab.Project, key, environmentScope string) (*gitlab.ProjectVariable, error) {
+	opts := &gitlab.GetProjectVariableOptions{Filter: &gitlab.VariableFilter{EnvironmentScope: environmentScope}}
+	v, resp, err := g.gitlab.ProjectVariables.GetVariable(p.ID, key, opts)
+	if nil != resp && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return v, err
+}
+
+// SetCIVariable creates the variable if it does not exist yet for the environment scope, and updates it otherwise.
+func (g *GitlabClient) SetCIVariable(p gitlab.Project, s CIVariableSettings) error {
+	if err := s.validate(); nil != err {
+		return err
+	}
+	existing, err := g.CIVariable(p, s.Key, s.scope())
+	if nil != err {
+		return err
+	}
+	return g.setVariable(fmt.Sprintf("projects/%d/variables", p.ID), nil != existing, s)
+}
+
+func (g *GitlabClient) setVariable(path string, exists bool, s CIVariableSettings) error {
+	scope := s.scope()
+	opts := &ciVariableOptions{
+		Value:            &s.Value,
+		Protected:        s.Protected,
+		Masked:           s.Masked,
+		Raw:              s.Raw,
+		EnvironmentScope: &scope,
+	}
+	if "" != s.VariableType {
+		opts.VariableType = &s.VariableType
+	}
+
+	method := http.MethodPost
+	if exists {
+		method = http.MethodPut
+		path = fmt.Sprintf("%s/%s", path, url.PathEscape(s.Key))
+		opts.Filter = &gitlab.VariableFilter{EnvironmentScope: scope}
+	} else {
+		opts.Key = &s.Key
+	}
+
+	req, err := g.gitlab.NewRequest(method, path, opts, nil)
+	if nil != err {
+		return err
+	}
+	_, err = g.gitlab.Do(req, nil)
+	return err
+}
+
+func (g *GitlabClient) RemoveCIVariable(p gitlab.Project, key, environmentScope string) error {
+	opts := &gitlab.RemoveProjectVariableOptions{Filter: &gitlab.VariableFilter{EnvironmentScope: environmentScope}}
+	_, err := g.gitlab.ProjectVariables.RemoveVariable(p.ID, key, opts)
+	return err
+}
+
+func (g *GitlabClient) Group(path string) (*gitlab.Group, error) {
+	withProjects := false
+	group, _, err := g.gitlab.Groups.GetGroup(path, &gitlab.GetGroupOptions{WithProjects: &withProjects})
+	return group, err
+}
+
+// AncestorGroups returns the groups a project belongs to, starting at its own namespace and ending at the top level group.
+func (g *GitlabClient) AncestorGroups(p gitlab.Project) ([]*gitlab.Group, error) {
+	var result []*gitlab.Group
+	if nil == p.Namespace || p.Namespace.Kind != "group" {
+		return result, nil
+	}
+
+	withProjects := false
+	groupID := p.Namespace.ID
+	for 0 != groupID {
+		group, _, err := g.gitlab.Groups.GetGroup(groupID, &gitlab.GetGroupOptions{WithProjects: &withProjects})
+		if nil != err {
+			return result, err
+		}
+		result = append(result, group)
+		groupID = group.ParentID
+	}
+	return result, nil
+}
+
+func (g *GitlabClient) GroupCIVariables(group gitlab.Group) ([]*gitlab.GroupVariable, error) {
+	opts := &gitlab.ListGroupVariablesOptions{PerPage: 100, Page: 1}
+	var result []*gitlab.GroupVariable
+	for {
+		variables, r, err := g.gitlab.GroupVariables.ListVariables(group.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, variables...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// SetGroupCIVariable creates the group variable if it does not exist yet for the environment scope, and updates it otherwise.
+func (g *GitlabClient) SetGroupCIVariable(group gitlab.Group, s CIVariableSettings) error {
+	if err := s.validate(); nil != err {
+		return err
+	}
+	variables, err := g.GroupCIVariables(group)
+	if nil != err {
+		return err
+	}
+	exists := false
+	for _, v := range variables {
+		if v.Key == s.Key && v.EnvironmentScope == s.scope() {
+			exists = true
+		}
+	}
+	return g.setVariable(fmt.Sprintf("groups/%d/variables", group.ID), exists, s)
+}
+
+func (g *GitlabClient) RemoveGroupCIVariable(group gitlab.Group, key, environmentScope string) error {
+	// The go-gitlab group variable removal does not support filtering on environment scope.
+	opts := &gitlab.RemoveProjectVariableOptions{Filter: &gitlab.VariableFilter{EnvironmentScope: environmentScope}}
+	path := fmt.Sprintf("groups/%d/variables/%s", group.ID, url.PathEscape(key))
+	req, err := g.gitlab.NewRequest(http.MethodDelete, path, opts, nil)
+	if nil != err {
+		return err
+	}
+	_, err = g.gitlab.Do(req, nil)
+	return err
 }
 
 func (g *GitlabClient) Pipelines(p gitlab.Project, branch string) ([]*gitlab.PipelineInfo, error) {
@@ -291,9 +643,561 @@ func (g *GitlabClient) Pipelines(p gitlab.Project, branch string) ([]*gitlab.Pip
 	return pipelines, err
 }
 
+// PipelinesSince returns the pipelines for the ref updated after since, newest first.
+func (g *GitlabClient) PipelinesSince(p gitlab.Project, ref string, since time.Time) ([]*gitlab.PipelineInfo, error) {
+	opts := &gitlab.ListProjectPipelinesOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}, Ref: &ref, UpdatedAfter: &since}
+	var result []*gitlab.PipelineInfo
+	for {
+		pipelines, r, err := g.gitlab.Pipelines.ListProjectPipelines(p.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, pipelines...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
 func (g *GitlabClient) JobsForPipeline(p gitlab.Project, pipeline gitlab.PipelineInfo) ([]*gitlab.Job, error) {
-	// This is paginated, but so far there has been no need to care for more than the first page.
-	jobs, _, err := g.gitlab.Jobs.ListPipelineJobs(p.ID, pipeline.ID, &gitlab.ListJobsOptions{})
+	return g.listPipelineJobs(p, pipeline.ID, false)
+}
 
-	return jobs, err
+// AllJobsForPipeline returns the jobs of the pipeline including those that were retried.
+func (g *GitlabClient) AllJobsForPipeline(p gitlab.Project, pipeline gitlab.PipelineInfo) ([]*gitlab.Job, error) {
+	return g.listPipelineJobs(p, pipeline.ID, true)
+}
+
+func (g *GitlabClient) listPipelineJobs(p gitlab.Project, id int, includeRetried bool) ([]*gitlab.Job, error) {
+	opts := &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}, IncludeRetried: &includeRetried}
+	var result []*gitlab.Job
+	for {
+		jobs, r, err := g.gitlab.Jobs.ListPipelineJobs(p.ID, id, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, jobs...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// PipelineJob is a job of a pipeline including the reason it failed, which go-gitlab does not support.
+type PipelineJob struct {
+	gitlab.Job
+	FailureReason string `json:"failure_reason"`
+}
+
+// PipelineJobs returns the jobs of the pipeline including the reason they failed.
+func (g *GitlabClient) PipelineJobs(p gitlab.Project, pipeline gitlab.PipelineInfo) ([]*PipelineJob, error) {
+	opts := &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+	var result []*PipelineJob
+	for {
+		req, err := g.gitlab.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/pipelines/%d/jobs", p.ID, pipeline.ID), opts, nil)
+		if nil != err {
+			return result, err
+		}
+		var jobs []*PipelineJob
+		r, err := g.gitlab.Do(req, &jobs)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, jobs...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// PipelineBridges returns the jobs of the pipeline that trigger downstream pipelines.
+func (g *GitlabClient) PipelineBridges(p gitlab.Project, pipeline gitlab.PipelineInfo) ([]*gitlab.Bridge, error) {
+	opts := &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+	var result []*gitlab.Bridge
+	for {
+		bridges, r, err := g.gitlab.Jobs.ListPipelineBridges(p.ID, pipeline.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, bridges...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// PipelineFilter selects pipelines, empty fields match any pipeline.
+type PipelineFilter struct {
+	Ref    string
+	SHA    string
+	Status string
+	// Source is what triggered the pipeline, such as push, merge_request_event or schedule.
+	Source string
+}
+
+// LatestPipeline returns the most recent pipeline matching the filter, or nil if there is none.
+func (g *GitlabClient) LatestPipeline(p gitlab.Project, f PipelineFilter) (*gitlab.PipelineInfo, error) {
+	opts := &gitlab.ListProjectPipelinesOptions{ListOptions: gitlab.ListOptions{PerPage: 1, Page: 1}}
+	if "" != f.Ref {
+		opts.Ref = &f.Ref
+	}
+	if "" != f.SHA {
+		opts.SHA = &f.SHA
+	}
+	if "" != f.Status {
+		opts.Status = gitlab.BuildState(gitlab.BuildStateValue(f.Status))
+	}
+	if "" != f.Source {
+		opts.Source = &f.Source
+	}
+	pipelines, _, err := g.gitlab.Pipelines.ListProjectPipelines(p.ID, opts)
+	if nil != err || len(pipelines) == 0 {
+		return nil, err
+	}
+	return pipelines[0], nil
+}
+
+func (g *GitlabClient) Pipeline(p gitlab.Project, id int) (*gitlab.Pipeline, error) {
+	pipeline, _, err := g.gitlab.Pipelines.GetPipeline(p.ID, id)
+	return pipeline, err
+}
+
+func (g *GitlabClient) CreatePipeline(p gitlab.Project, ref string, variables []*gitlab.PipelineVariable) (*gitlab.Pipeline, error) {
+	opts := &gitlab.CreatePipelineOptions{Ref: &ref}
+	if len(variables) > 0 {
+		opts.Variables = &variables
+	}
+	pipeline, _, err := g.gitlab.Pipelines.CreatePipeline(p.ID, opts)
+	return pipeline, err
+}
+
+// RetryPipeline retries the failed and canceled jobs of the pipeline.
+func (g *GitlabClient) RetryPipeline(p gitlab.Project, id int) (*gitlab.Pipeline, error) {
+	pipeline, _, err := g.gitlab.Pipelines.RetryPipelineBuild(p.ID, id)
+	return pipeline, err
+}
+
+func (g *GitlabClient) CancelPipeline(p gitlab.Project, id int) (*gitlab.Pipeline, error) {
+	pipeline, _, err := g.gitlab.Pipelines.CancelPipelineBuild(p.ID, id)
+	return pipeline, err
+}
+
+func (g *GitlabClient) Job(p gitlab.Project, id int) (*gitlab.Job, error) {
+	job, _, err := g.gitlab.Jobs.GetJob(p.ID, id)
+	return job, err
+}
+
+func (g *GitlabClient) RetryJob(p gitlab.Project, id int) (*gitlab.Job, error) {
+	job, _, err := g.gitlab.Jobs.RetryJob(p.ID, id)
+	return job, err
+}
+
+func (g *GitlabClient) CancelJob(p gitlab.Project, id int) (*gitlab.Job, error) {
+	job, _, err := g.gitlab.Jobs.CancelJob(p.ID, id)
+	return job, err
+}
+
+func (g *GitlabClient) PlayJob(p gitlab.Project, id int) (*gitlab.Job, error) {
+	job, _, err := g.gitlab.Jobs.PlayJob(p.ID, id)
+	return job, err
+}
+
+// JobTrace returns the log of the job as far as it has been written.
+func (g *GitlabClient) JobTrace(p gitlab.Project, id int) ([]byte, error) {
+	trace, _, err := g.gitlab.Jobs.GetTraceFile(p.ID, id)
+	if nil != err {
+		return nil, err
+	}
+	return ioutil.ReadAll(trace)
+}
+
+// JobArtifacts returns the artifacts archive of the job.
+func (g *GitlabClient) JobArtifacts(p gitlab.Project, id int) (*bytes.Reader, error) {
+	artifacts, _, err := g.gitlab.Jobs.GetJobArtifacts(p.ID, id)
+	return artifacts, err
+}
+
+// LatestArtifacts returns the artifacts archive of the job with the given name in the latest successful pipeline for the ref.
+func (g *GitlabClient) LatestArtifacts(p gitlab.Project, ref, job string) (*bytes.Reader, error) {
+	artifacts, _, err := g.gitlab.Jobs.DownloadArtifactsFile(p.ID, url.PathEscape(ref), &gitlab.DownloadArtifactsFileOptions{Job: &job})
+	return artifacts, err
+}
+
+// TriggerPipeline creates a pipeline using a pipeline trigger token, which runs as the owner of the trigger.
+func (g *GitlabClient) TriggerPipeline(p gitlab.Project, ref, token string, variables map[string]string) (*gitlab.Pipeline, error) {
+	pipeline, _, err := g.gitlab.PipelineTriggers.RunPipelineTrigger(p.ID, &gitlab.RunPipelineTriggerOptions{
+		Ref:       &ref,
+		Token:     &token,
+		Variables: variables,
+	})
+	return pipeline, err
+}
+
+// PushRuleSettings holds the push rules to change, nil fields are left as they are.
+// The mapstructure tags allow reading them from golab-config.
+type PushRuleSettings struct {
+	CommitMessageRegex         *string `mapstructure:"commit-message-regex"`
+	CommitMessageNegativeRegex *string `mapstructure:"commit-message-negative-regex"`
+	BranchNameRegex            *string `mapstructure:"branch-name-regex"`
+	AuthorEmailRegex           *string `mapstructure:"author-email-regex"`
+	FileNameRegex              *string `mapstructure:"file-name-regex"`
+	MaxFileSize                *int    `mapstructure:"max-file-size"`
+	DenyDeleteTag              *bool   `mapstructure:"deny-delete-tag"`
+	MemberCheck                *bool   `mapstructure:"member-check"`
+	PreventSecrets             *bool   `mapstructure:"prevent-secrets"`
+	CommitCommitterCheck       *bool   `mapstructure:"commit-committer-check"`
+	RejectUnsignedCommits      *bool   `mapstructure:"reject-unsigned-commits"`
+}
+
+func (s *PushRuleSettings) HasChanges() bool {
+	return nil != s.CommitMessageRegex || nil != s.CommitMessageNegativeRegex || nil != s.BranchNameRegex ||
+		nil != s.AuthorEmailRegex || nil != s.FileNameRegex || nil != s.MaxFileSize || nil != s.DenyDeleteTag ||
+		nil != s.MemberCheck || nil != s.PreventSecrets || nil != s.CommitCommitterCheck || nil != s.RejectUnsignedCommits
+}
+
+// PushRules returns the push rules of the project, or nil if it has none.
+func (g *GitlabClient) PushRules(p gitlab.Project) (*gitlab.ProjectPushRules, error) {
+	rules, _, err := g.gitlab.Projects.GetProjectPushRules(p.ID)
+	if nil != err || 0 == rules.ID {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SetPushRules adds the push rules to the project if it has none yet, and edits them otherwise.
+func (g *GitlabClient) SetPushRules(p gitlab.Project, s PushRuleSettings) error {
+	if !s.HasChanges() {
+		return nil
+	}
+	existing, err := g.PushRules(p)
+	if nil != err {
+		return err
+	}
+
+	if nil == existing {
+		_, _, err = g.gitlab.Projects.AddProjectPushRule(p.ID, &gitlab.AddProjectPushRuleOptions{
+			CommitMessageRegex:         s.CommitMessageRegex,
+			CommitMessageNegativeRegex: s.CommitMessageNegativeRegex,
+			BranchNameRegex:            s.BranchNameRegex,
+			AuthorEmailRegex:           s.AuthorEmailRegex,
+			FileNameRegex:              s.FileNameRegex,
+			MaxFileSize:                s.MaxFileSize,
+			DenyDeleteTag:              s.DenyDeleteTag,
+			MemberCheck:                s.MemberCheck,
+			PreventSecrets:             s.PreventSecrets,
+			CommitCommitterCheck:       s.CommitCommitterCheck,
+			RejectUnsignedCommits:      s.RejectUnsignedCommits,
+		})
+		return err
+	}
+	_, _, err = g.gitlab.Projects.EditProjectPushRule(p.ID, &gitlab.EditProjectPushRuleOptions{
+		CommitMessageRegex:         s.CommitMessageRegex,
+		CommitMessageNegativeRegex: s.CommitMessageNegativeRegex,
+		BranchNameRegex:            s.BranchNameRegex,
+		AuthorEmailRegex:           s.AuthorEmailRegex,
+		FileNameRegex:              s.FileNameRegex,
+		MaxFileSize:                s.MaxFileSize,
+		DenyDeleteTag:              s.DenyDeleteTag,
+		MemberCheck:                s.MemberCheck,
+		PreventSecrets:             s.PreventSecrets,
+		CommitCommitterCheck:       s.CommitCommitterCheck,
+		RejectUnsignedCommits:      s.RejectUnsignedCommits,
+	})
+	return err
+}
+
+// User returns the user with the given username, or nil if there is none.
+func (g *GitlabClient) User(username string) (*gitlab.User, error) {
+	users, _, err := g.gitlab.Users.ListUsers(&gitlab.ListUsersOptions{Username: &username})
+	if nil != err || len(users) == 0 {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+type ApprovalSettings struct {
+	ApprovalsBeforeMerge                      *int
+	ResetApprovalsOnPush                      *bool
+	DisableOverridingApproversPerMergeRequest *bool
+	MergeRequestsAuthorApproval               *bool
+	MergeRequestsDisableCommittersApproval    *bool
+	RequirePasswordToApprove                  *bool
+}
+
+func (s *ApprovalSettings) HasChanges() bool {
+	return nil != s.ApprovalsBeforeMerge || nil != s.ResetApprovalsOnPush || nil != s.DisableOverridingApproversPerMergeRequest ||
+		nil != s.MergeRequestsAuthorApproval || nil != s.MergeRequestsDisableCommittersApproval || nil != s.RequirePasswordToApprove
+}
+
+func (g *GitlabClient) ApprovalConfiguration(p gitlab.Project) (*gitlab.ProjectApprovals, error) {
+	approvals, _, err := g.gitlab.Projects.GetApprovalConfiguration(p.ID)
+	return approvals, err
+}
+
+func (g *GitlabClient) SetApprovalConfiguration(p gitlab.Project, s ApprovalSettings) error {
+	if !s.HasChanges() {
+		return nil
+	}
+	_, _, err := g.gitlab.Projects.ChangeApprovalConfiguration(p.ID, &gitlab.ChangeApprovalConfigurationOptions{
+		ApprovalsBeforeMerge:                      s.ApprovalsBeforeMerge,
+		ResetApprovalsOnPush:                      s.ResetApprovalsOnPush,
+		DisableOverridingApproversPerMergeRequest: s.DisableOverridingApproversPerMergeRequest,
+		MergeRequestsAuthorApproval:               s.MergeRequestsAuthorApproval,
+		MergeRequestsDisableCommittersApproval:    s.MergeRequestsDisableCommittersApproval,
+		RequirePasswordToApprove:                  s.RequirePasswordToApprove,
+	})
+	return err
+}
+
+func (g *GitlabClient) ApprovalRules(p gitlab.Project) ([]*gitlab.ProjectApprovalRule, error) {
+	rules, _, err := g.gitlab.Projects.GetProjectApprovalRules(p.ID)
+	return rules, err
+}
+
+// ApprovalRuleSettings holds a project approval rule. Users are referred to by username, groups by full path and
+// protected branches by name. Nil fields are left as they are when updating a rule.
+type ApprovalRuleSettings struct {
+	Name              string
+	ApprovalsRequired *int
+	Users             *[]string
+	Groups            *[]string
+	ProtectedBranches *[]string
+}
+
+// approvalRuleIDs resolves the users, groups and protected branches of the rule to their IDs.
+func (g *GitlabClient) approvalRuleIDs(p gitlab.Project, s ApprovalRuleSettings) (users, groups, branches *[]int, err error) {
+	if nil != s.Users {
+		ids := []int{}
+		for _, username := range *s.Users {
+			u, err := g.User(username)
+			if nil != err {
+				return nil, nil, nil, err
+			}
+			if nil == u {
+				return nil, nil, nil, fmt.Errorf("No user %s found", username)
+			}
+			ids = append(ids, u.ID)
+		}
+		users = &ids
+	}
+	if nil != s.Groups {
+		ids := []int{}
+		for _, path := range *s.Groups {
+			group, err := g.Group(path)
+			if nil != err {
+				return nil, nil, nil, err
+			}
+			ids = append(ids, group.ID)
+		}
+		groups = &ids
+	}
+	if nil != s.ProtectedBranches {
+		ids := []int{}
+		for _, name := range *s.ProtectedBranches {
+			pb, err := g.ProtectedBranch(p, name)
+			if nil != err {
+				return nil, nil, nil, err
+			}
+			if nil == pb {
+				return nil, nil, nil, fmt.Errorf("Branch %s is not protected", name)
+			}
+			ids = append(ids, pb.ID)
+		}
+		branches = &ids
+	}
+	return users, groups, branches, nil
+}
+
+func (g *GitlabClient) CreateApprovalRule(p gitlab.Project, s ApprovalRuleSettings) error {
+	users, groups, branches, err := g.approvalRuleIDs(p, s)
+	if nil != err {
+		return err
+	}
+	approvalsRequired := 0
+	if nil != s.ApprovalsRequired {
+		approvalsRequired = *s.ApprovalsRequired
+	}
+	_, _, err = g.gitlab.Projects.CreateProjectApprovalRule(p.ID, &gitlab.CreateProjectLevelRuleOptions{
+		Name:               &s.Name,
+		ApprovalsRequired:  &approvalsRequired,
+		UserIDs:            users,
+		GroupIDs:           groups,
+		ProtectedBranchIDs: branches,
+	})
+	return err
+}
+
+// UpdateApprovalRule changes the rule to the settings, the rule is renamed when the name in the settings differs.
+func (g *GitlabClient) UpdateApprovalRule(p gitlab.Project, rule gitlab.ProjectApprovalRule, s ApprovalRuleSettings) error {
+	users, groups, branches, err := g.approvalRuleIDs(p, s)
+	if nil != err {
+		return err
+	}
+	opts := &gitlab.UpdateProjectLevelRuleOptions{
+		ApprovalsRequired:  s.ApprovalsRequired,
+		UserIDs:            users,
+		GroupIDs:           groups,
+		ProtectedBranchIDs: branches,
+	}
+	if "" != s.Name {
+		opts.Name = &s.Name
+	}
+	_, _, err = g.gitlab.Projects.UpdateProjectApprovalRule(p.ID, rule.ID, opts)
+	return err
+}
+
+func (g *GitlabClient) RemoveApprovalRule(p gitlab.Project, rule gitlab.ProjectApprovalRule) error {
+	_, err := g.gitlab.Projects.DeleteProjectApprovalRule(p.ID, rule.ID)
+	return err
+}
+
+func (g *GitlabClient) PipelineSchedules(p gitlab.Project) ([]*gitlab.PipelineSchedule, error) {
+	opts := &gitlab.ListPipelineSchedulesOptions{PerPage: 100, Page: 1}
+	var result []*gitlab.PipelineSchedule
+	for {
+		schedules, r, err := g.gitlab.PipelineSchedules.ListPipelineSchedules(p.ID, opts)
+		if nil != err {
+			return result, err
+		}
+		result = append(result, schedules...)
+		if r.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = r.NextPage
+	}
+}
+
+// PipelineSchedule returns the schedule including its variables, or nil if there is none.
+func (g *GitlabClient) PipelineSchedule(p gitlab.Project, id int) (*gitlab.PipelineSchedule, error) {
+	schedule, resp, err := g.gitlab.PipelineSchedules.GetPipelineSchedule(p.ID, id)
+	if nil != resp && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+// PipelineScheduleSettings holds the schedule to change, nil fields are left as they are.
+type PipelineScheduleSettings struct {
+	Description  *string
+	Ref          *string
+	Cron         *string
+	CronTimezone *string
+	Active       *bool
+}
+
+func (s *PipelineScheduleSettings) HasChanges() bool {
+	return nil != s.Description || nil != s.Ref || nil != s.Cron || nil != s.CronTimezone || nil != s.Active
+}
+
+func (g *GitlabClient) CreatePipelineSchedule(p gitlab.Project, s PipelineScheduleSettings) (*gitlab.PipelineSchedule, error) {
+	schedule, _, err := g.gitlab.PipelineSchedules.CreatePipelineSchedule(p.ID, &gitlab.CreatePipelineScheduleOptions{
+		Description:  s.Description,
+		Ref:          s.Ref,
+		Cron:         s.Cron,
+		CronTimezone: s.CronTimezone,
+		Active:       s.Active,
+	})
+	return schedule, err
+}
+
+func (g *GitlabClient) EditPipelineSchedule(p gitlab.Project, id int, s PipelineScheduleSettings) error {
+	if !s.HasChanges() {
+		return nil
+	}
+	_, _, err := g.gitlab.PipelineSchedules.EditPipelineSchedule(p.ID, id, &gitlab.EditPipelineScheduleOptions{
+		Description:  s.Description,
+		Ref:          s.Ref,
+		Cron:         s.Cron,
+		CronTimezone: s.CronTimezone,
+		Active:       s.Active,
+	})
+	return err
+}
+
+// TakeOwnershipOfPipelineSchedule makes the current user the owner of the schedule, its pipelines then run as them.
+func (g *GitlabClient) TakeOwnershipOfPipelineSchedule(p gitlab.Project, id int) (*gitlab.PipelineSchedule, error) {
+	schedule, _, err := g.gitlab.PipelineSchedules.TakeOwnershipOfPipelineSchedule(p.ID, id)
+	return schedule, err
+}
+
+func (g *GitlabClient) RemovePipelineSchedule(p gitlab.Project, id int) error {
+	_, err := g.gitlab.PipelineSchedules.DeletePipelineSchedule(p.ID, id)
+	return err
+}
+
+// RunPipelineSchedule starts a pipeline for the schedule right away, without changing when it runs next.
+func (g *GitlabClient) RunPipelineSchedule(p gitlab.Project, id int) error {
+	_, err := g.gitlab.PipelineSchedules.RunPipelineSchedule(p.ID, id)
+	return err
+}
+
+// SetPipelineScheduleVariable adds the variable to the schedule, or changes it when the schedule already has it.
+func (g *GitlabClient) SetPipelineScheduleVariable(p gitlab.Project, schedule gitlab.PipelineSchedule, v gitlab.PipelineVariable) error {
+	variableType := v.VariableType
+	if "" == variableType {
+		variableType = "env_var"
+	}
+	for _, existing := range schedule.Variables {
+		if existing.Key != v.Key {
+			continue
+		}
+		_, _, err := g.gitlab.PipelineSchedules.EditPipelineScheduleVariable(p.ID, schedule.ID, v.Key, &gitlab.EditPipelineScheduleVariableOptions{
+			Value:        &v.Value,
+			VariableType: &variableType,
+		})
+		return err
+	}
+	_, _, err := g.gitlab.PipelineSchedules.CreatePipelineScheduleVariable(p.ID, schedule.ID, &gitlab.CreatePipelineScheduleVariableOptions{
+		Key:          &v.Key,
+		Value:        &v.Value,
+		VariableType: &variableType,
+	})
+	return err
+}
+
+func (g *GitlabClient) RemovePipelineScheduleVariable(p gitlab.Project, schedule gitlab.PipelineSchedule, key string) error {
+	_, _, err := g.gitlab.PipelineSchedules.DeletePipelineScheduleVariable(p.ID, schedule.ID, key)
+	return err
+}
+
+// IsProjectMember returns whether the user is a member of the project, directly or through its groups.
+func (g *GitlabClient) IsProjectMember(p gitlab.Project, userID int) (bool, error) {
+	_, resp, err := g.gitlab.ProjectMembers.GetInheritedProjectMember(p.ID, userID)
+	if nil != resp && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return nil == err, err
+}
+
+// RawFile returns the content of the file in the repository of the project at the ref.
+func (g *GitlabClient) RawFile(p gitlab.Project, path, ref string) ([]byte, error) {
+	content, _, err := g.gitlab.RepositoryFiles.GetRawFile(p.ID, path, &gitlab.GetRawFileOptions{Ref: &ref})
+	return content, err
+}
+
+// LintCIConfig validates the CI configuration in the context of the project, so includes of the project resolve.
+// With simulate the creation of a pipeline on the default branch is simulated, which also validates rules and needs.
+func (g *GitlabClient) LintCIConfig(p gitlab.Project, content string, simulate bool) (*gitlab.ProjectLintResult, error) {
+	result, _, err := g.gitlab.Validate.ProjectNamespaceLint(p.ID, &gitlab.ProjectNamespaceLintOptions{Content: &content, DryRun: &simulate})
+	return result, err
+}
+
+// LintProjectCIConfig validates the CI configuration of the project at the ref.
+func (g *GitlabClient) LintProjectCIConfig(p gitlab.Project, ref string, simulate bool) (*gitlab.ProjectLintResult, error) {
+	// The go-gitlab project lint does not support giving the ref.
+	opts := struct {
+		Ref    *string `url:"ref,omitempty"`
+		DryRun *bool   `url:"dry_run,omitempty"`
+	}{&ref, &simulate}
+	req, err := g.gitlab.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d/ci/lint", p.ID), &opts, nil)
+	if nil != err {
+		return nil, err
+	}
+	result := &gitlab.ProjectLintResult{}
+	_, err = g.gitlab.Do(req, result)
+	return result, err
 }